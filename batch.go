@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"vpc-import-cli/common"
+	"vpc-import-cli/genvars"
+	"vpc-import-cli/tf_import"
+)
+
+// the root terraform config copied into each stack's working directory
+var rootConfigFiles = []string{"main.tf", "variables.tf", "providers.tf", "modules"}
+
+// batchOptions are the single stack flags, applied to every stack in the batch
+type batchOptions struct {
+	genvars      bool
+	importStack  bool
+	importBlocks bool
+	resume       bool
+	allowDrift   bool
+	verify       bool
+	mapping      tf_import.Mapping
+	concurrency  int
+}
+
+// batchStack is a stack to process, read with its own clients when the batch spans accounts or regions
+type batchStack struct {
+	stackName                string
+	workingDir               string
+	region                   string
+	roleArn                  string
+	cfn_client_p             common.StackDescriber
+	ec2_client_p             common.VpcDescriber
+	route53resolver_client_p common.ResolverRuleLister
+}
+
+// stackResult is the outcome of running the batch for a single stack
+type stackResult struct {
+	stackName  string
+	workingDir string
+	imported   int
+	skipped    int
+	failed     int
+	changes    int
+	err        error
+}
+
+// batchStackNames returns the stack names, else reads the stacks file, else lists the stacks whose name
+// matches the pattern
+func batchStackNames(cfn_client_p common.StackDescriber, stackNames []string, stacksFile string, stackPattern string) ([]string, error) {
+	if len(stackNames) > 0 {
+		return stackNames, nil
+	}
+	if stacksFile != "" {
+		return readStacksFile(stacksFile)
+	}
+	pattern, err := regexp.Compile(stackPattern)
+	if err != nil {
+		return nil, fmt.Errorf("parsing --stack-pattern: %w", err)
+	}
+	return common.ListStackNames(cfn_client_p, pattern)
+}
+
+// resolveBatchStacks lists the target's stacks with clients for its role and region, each stack gets a working
+// directory under workDir
+func resolveBatchStacks(target inventoryTarget, stacksFile string, snapshotPath string, workDir string) ([]batchStack, error) {
+	cfn_client_p, ec2_client_p, route53resolver_client_p, err := newClients(snapshotPath, target.Region, target.RoleArn)
+	if err != nil {
+		return nil, err
+	}
+	stackNames, err := batchStackNames(cfn_client_p, target.Stacks, stacksFile, target.StackPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	stacks := []batchStack{}
+	for _, stackName := range stackNames {
+		stacks = append(stacks, batchStack{
+			stackName:                stackName,
+			workingDir:               filepath.Join(workDir, stackName),
+			region:                   target.Region,
+			roleArn:                  target.RoleArn,
+			cfn_client_p:             cfn_client_p,
+			ec2_client_p:             ec2_client_p,
+			route53resolver_client_p: route53resolver_client_p,
+		})
+	}
+	return stacks, nil
+}
+
+// readStacksFile reads one stack name per line, blank lines and lines starting with # are ignored
+func readStacksFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stackNames := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		stackNames = append(stackNames, line)
+	}
+	return stackNames, scanner.Err()
+}
+
+// runBatch processes the stacks with a pool of options.concurrency workers, each stack in its own
+// working directory. The returned error joins every failed stack's error
+func runBatch(stacks []batchStack, options batchOptions) ([]stackResult, error) {
+	results := make([]stackResult, len(stacks))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < options.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index] = processStack(stacks[index], options)
+			}
+		}()
+	}
+	for index := range stacks {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	var batchErr error
+	for _, result := range results {
+		if result.err != nil {
+			batchErr = errors.Join(batchErr, fmt.Errorf("stack %s: %w", result.stackName, result.err))
+		}
+	}
+	return results, batchErr
+}
+
+func processStack(stack batchStack, options batchOptions) stackResult {
+	cfn_client_p, ec2_client_p, route53resolver_client_p := stack.cfn_client_p, stack.ec2_client_p, stack.route53resolver_client_p
+	stackName := stack.stackName
+	result := stackResult{stackName: stackName, workingDir: stack.workingDir}
+	log.Printf("Processing stack %s in %s", stackName, result.workingDir)
+	if result.err = copyRootConfig(result.workingDir); result.err != nil {
+		return result
+	}
+	if options.importStack || options.verify {
+		if result.err = writeProviderOverride(result.workingDir, stack.region, stack.roleArn); result.err != nil {
+			return result
+		}
+	}
+
+	if options.importStack {
+		// drift is only reported in the summary error, the per property table would interleave with other stacks
+		if _, result.err = tf_import.Preflight(cfn_client_p, options.mapping, &stackName, options.allowDrift); result.err != nil {
+			return result
+		}
+	}
+
+	if options.genvars {
+		if result.err = genvars.Genvars(cfn_client_p, ec2_client_p, route53resolver_client_p, &stackName, result.workingDir); result.err != nil {
+			return result
+		}
+	}
+
+	if options.importStack {
+		var importResults []tf_import.ImportResult
+		if options.importBlocks {
+			importResults, result.err = tf_import.TerraformImportBlocks(cfn_client_p, ec2_client_p, route53resolver_client_p, options.mapping, &stackName, result.workingDir, options.resume,
+				// --auto-approve is required for import blocks in a batch
+				func(step string) bool { return true })
+		} else {
+			importResults, result.err = tf_import.TerraformImport(cfn_client_p, ec2_client_p, route53resolver_client_p, options.mapping, &stackName, result.workingDir, options.resume)
+		}
+		for _, importResult := range importResults {
+			switch {
+			case importResult.Err != nil:
+				result.failed++
+			case importResult.Skipped:
+				result.skipped++
+			default:
+				result.imported++
+			}
+		}
+		if result.err != nil {
+			return result
+		}
+	}
+
+	if options.verify {
+		var changes []tf_import.PlannedChange
+		changes, result.err = tf_import.TerraformVerify(result.workingDir)
+		result.changes = len(changes)
+	}
+	return result
+}
+
+// copyRootConfig copies the root terraform config into the working directory, files already there are kept
+// so a stack's config can be edited between runs
+func copyRootConfig(workingDir string) error {
+	if err := os.MkdirAll(workingDir, 0755); err != nil {
+		return err
+	}
+	for _, name := range rootConfigFiles {
+		err := filepath.WalkDir(name, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(workingDir, path)
+			if entry.IsDir() {
+				return os.MkdirAll(target, 0755)
+			}
+			if _, err := os.Stat(target); err == nil {
+				return nil
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, content, 0644)
+		})
+		if err != nil {
+			return fmt.Errorf("copying %s to %s: %w", name, workingDir, err)
+		}
+	}
+	return nil
+}
+
+func printBatchSummary(out io.Writer, results []stackResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tSTACK\tWORKING DIR\tIMPORTED\tSKIPPED\tFAILED\tPLAN CHANGES\tERROR\t")
+	failedCount := 0
+	for _, result := range results {
+		status := "ok"
+		errMsg := "-"
+		if result.err != nil {
+			status = "FAILED"
+			// only the first line, terraform errors span many
+			errMsg = strings.SplitN(result.err.Error(), "\n", 2)[0]
+			failedCount++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t\n", status, result.stackName, result.workingDir, result.imported, result.skipped, result.failed, result.changes, errMsg)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d stacks succeeded, %d failed\n", len(results)-failedCount, failedCount)
+}
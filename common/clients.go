@@ -0,0 +1,50 @@
+package common
+
+import (
+	"context"
+
+	cfn "github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
+)
+
+// narrow interfaces over the aws sdk clients, covering only the calls the cli makes, so it can run against
+// the fake package instead of aws. *cloudformation.Client, *ec2.Client and *route53resolver.Client implement them
+
+type StackDescriber interface {
+	DescribeStacks(ctx context.Context, params *cfn.DescribeStacksInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStacksOutput, error)
+	DescribeStackResources(ctx context.Context, params *cfn.DescribeStackResourcesInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStackResourcesOutput, error)
+	ListStacks(ctx context.Context, params *cfn.ListStacksInput, optFns ...func(*cfn.Options)) (*cfn.ListStacksOutput, error)
+	DetectStackDrift(ctx context.Context, params *cfn.DetectStackDriftInput, optFns ...func(*cfn.Options)) (*cfn.DetectStackDriftOutput, error)
+	DescribeStackDriftDetectionStatus(ctx context.Context, params *cfn.DescribeStackDriftDetectionStatusInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStackDriftDetectionStatusOutput, error)
+	DescribeStackResourceDrifts(ctx context.Context, params *cfn.DescribeStackResourceDriftsInput, optFns ...func(*cfn.Options)) (*cfn.DescribeStackResourceDriftsOutput, error)
+	ListImports(ctx context.Context, params *cfn.ListImportsInput, optFns ...func(*cfn.Options)) (*cfn.ListImportsOutput, error)
+}
+
+// StackRetirer adds the calls that change a stack, only the retire-stack command needs it
+type StackRetirer interface {
+	StackDescriber
+	GetTemplate(ctx context.Context, params *cfn.GetTemplateInput, optFns ...func(*cfn.Options)) (*cfn.GetTemplateOutput, error)
+	CreateChangeSet(ctx context.Context, params *cfn.CreateChangeSetInput, optFns ...func(*cfn.Options)) (*cfn.CreateChangeSetOutput, error)
+	DescribeChangeSet(ctx context.Context, params *cfn.DescribeChangeSetInput, optFns ...func(*cfn.Options)) (*cfn.DescribeChangeSetOutput, error)
+	ExecuteChangeSet(ctx context.Context, params *cfn.ExecuteChangeSetInput, optFns ...func(*cfn.Options)) (*cfn.ExecuteChangeSetOutput, error)
+	DeleteChangeSet(ctx context.Context, params *cfn.DeleteChangeSetInput, optFns ...func(*cfn.Options)) (*cfn.DeleteChangeSetOutput, error)
+	DeleteStack(ctx context.Context, params *cfn.DeleteStackInput, optFns ...func(*cfn.Options)) (*cfn.DeleteStackOutput, error)
+}
+
+type VpcDescriber interface {
+	DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error)
+	DescribeDhcpOptions(ctx context.Context, params *ec2.DescribeDhcpOptionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeDhcpOptionsOutput, error)
+	DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error)
+	DescribeSecurityGroupRules(ctx context.Context, params *ec2.DescribeSecurityGroupRulesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupRulesOutput, error)
+	DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error)
+	DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error)
+	DescribeTransitGatewayVpcAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayVpcAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error)
+	DescribeTransitGatewayAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayAttachmentsOutput, error)
+	GetTransitGatewayAttachmentPropagations(ctx context.Context, params *ec2.GetTransitGatewayAttachmentPropagationsInput, optFns ...func(*ec2.Options)) (*ec2.GetTransitGatewayAttachmentPropagationsOutput, error)
+}
+
+type ResolverRuleLister interface {
+	ListResolverRules(ctx context.Context, params *route53resolver.ListResolverRulesInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRulesOutput, error)
+	ListResolverRuleAssociations(ctx context.Context, params *route53resolver.ListResolverRuleAssociationsInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRuleAssociationsOutput, error)
+}
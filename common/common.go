@@ -2,11 +2,18 @@ package common
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	cfn "github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	cfn_types "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2_types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
 	route53resolver_types "github.com/aws/aws-sdk-go-v2/service/route53resolver/types"
 )
@@ -20,29 +27,258 @@ const (
 	ResolverRuleInternet       = "Internet Resolver"
 )
 
-func GetStacksOutput(cfn_client_p *cfn.Client, stackName_p *string) *cfn.DescribeStacksOutput {
+func GetStacksOutput(cfn_client_p StackDescriber, stackName_p *string) (*cfn.DescribeStacksOutput, error) {
 	stacksInput := cfn.DescribeStacksInput{StackName: stackName_p}
 	stacksOutput_p, err := cfn_client_p.DescribeStacks(context.TODO(), &stacksInput)
-	Check(err)
-	return stacksOutput_p
+	if isStackNotFound(err) {
+		return nil, fmt.Errorf("%w: %s", ErrStackNotFound, *stackName_p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("describing stack %s: %w", *stackName_p, err)
+	}
+	return stacksOutput_p, nil
 }
 
-func GetStackResourcesOutput(cfn_client_p *cfn.Client, stackName_p *string) *cfn.DescribeStackResourcesOutput {
+func GetStackResourcesOutput(cfn_client_p StackDescriber, stackName_p *string) (*cfn.DescribeStackResourcesOutput, error) {
 	stackResourcesInput := cfn.DescribeStackResourcesInput{StackName: stackName_p}
 	stackResourcesOutput, err := cfn_client_p.DescribeStackResources(context.TODO(), &stackResourcesInput)
-	Check(err)
-	return stackResourcesOutput
+	if isStackNotFound(err) {
+		return nil, fmt.Errorf("%w: %s", ErrStackNotFound, *stackName_p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("describing resources of stack %s: %w", *stackName_p, err)
+	}
+	return stackResourcesOutput, nil
 }
 
-func GetDhcpOptionsIdFromVpc(ec2_client_p *ec2.Client, physicalResourceId string) string {
+func GetDhcpOptionsIdFromVpc(ec2_client_p VpcDescriber, physicalResourceId string) (string, error) {
 	input := ec2.DescribeVpcsInput{VpcIds: []string{physicalResourceId}}
 	output, err := ec2_client_p.DescribeVpcs(context.TODO(), &input)
-	Check(err)
-	return *output.Vpcs[0].DhcpOptionsId
+	if err != nil {
+		return "", fmt.Errorf("describing vpc %s: %w", physicalResourceId, err)
+	}
+	if len(output.Vpcs) == 0 {
+		return "", fmt.Errorf("%w: vpc %s", ErrResourceNotFound, physicalResourceId)
+	}
+	return *output.Vpcs[0].DhcpOptionsId, nil
+}
+
+// GetVpcRange returns the vpc's cidr block
+func GetVpcRange(ec2_client_p VpcDescriber, physicalResourceId string) (string, error) {
+	input := ec2.DescribeVpcsInput{VpcIds: []string{physicalResourceId}}
+	output, err := ec2_client_p.DescribeVpcs(context.TODO(), &input)
+	if err != nil {
+		return "", fmt.Errorf("describing vpc %s: %w", physicalResourceId, err)
+	}
+	if len(output.Vpcs) == 0 {
+		return "", fmt.Errorf("%w: vpc %s", ErrResourceNotFound, physicalResourceId)
+	}
+	return *output.Vpcs[0].CidrBlock, nil
+}
+
+// GetTgwAttachmentAssociatedRouteTableId returns the transit gateway route table the attachment is associated
+// with, empty when it isn't associated
+func GetTgwAttachmentAssociatedRouteTableId(ec2_client_p VpcDescriber, tgwAttachmentId string) (string, error) {
+	input := ec2.DescribeTransitGatewayAttachmentsInput{TransitGatewayAttachmentIds: []string{tgwAttachmentId}}
+	output, err := ec2_client_p.DescribeTransitGatewayAttachments(context.TODO(), &input)
+	if err != nil {
+		return "", fmt.Errorf("describing transit gateway attachment %s: %w", tgwAttachmentId, err)
+	}
+	if len(output.TransitGatewayAttachments) == 0 {
+		return "", fmt.Errorf("%w: transit gateway attachment %s", ErrResourceNotFound, tgwAttachmentId)
+	}
+	association := output.TransitGatewayAttachments[0].Association
+	if association == nil || association.State != ec2_types.TransitGatewayAssociationStateAssociated {
+		return "", nil
+	}
+	return aws.ToString(association.TransitGatewayRouteTableId), nil
+}
+
+// GetTgwAttachmentPropagatedRouteTableIds returns the transit gateway route tables the attachment propagates to
+func GetTgwAttachmentPropagatedRouteTableIds(ec2_client_p VpcDescriber, tgwAttachmentId string) ([]string, error) {
+	routeTableIds := []string{}
+	input := ec2.GetTransitGatewayAttachmentPropagationsInput{TransitGatewayAttachmentId: &tgwAttachmentId}
+	for {
+		output, err := ec2_client_p.GetTransitGatewayAttachmentPropagations(context.TODO(), &input)
+		if err != nil {
+			return nil, fmt.Errorf("getting propagations of transit gateway attachment %s: %w", tgwAttachmentId, err)
+		}
+		for _, propagation := range output.TransitGatewayAttachmentPropagations {
+			if propagation.State == ec2_types.TransitGatewayPropagationStateEnabled {
+				routeTableIds = append(routeTableIds, aws.ToString(propagation.TransitGatewayRouteTableId))
+			}
+		}
+		if output.NextToken == nil {
+			return routeTableIds, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// GetTgwAttachmentMSKRouteTableId returns the route table the attachment propagates to other than its associated
+// route table, the msk one. Empty unless there's exactly one such propagation, otherwise which one is msk's can't be told
+func GetTgwAttachmentMSKRouteTableId(ec2_client_p VpcDescriber, tgwAttachmentId string, associatedRouteTableId string) (string, error) {
+	propagatedRouteTableIds, err := GetTgwAttachmentPropagatedRouteTableIds(ec2_client_p, tgwAttachmentId)
+	if err != nil {
+		return "", err
+	}
+	mskRouteTableIds := []string{}
+	for _, routeTableId := range propagatedRouteTableIds {
+		if routeTableId != associatedRouteTableId {
+			mskRouteTableIds = append(mskRouteTableIds, routeTableId)
+		}
+	}
+	if len(mskRouteTableIds) != 1 {
+		return "", nil
+	}
+	return mskRouteTableIds[0], nil
+}
+
+// cloudformation tags every resource it creates with the name of its stack
+const stackNameTagKey = "aws:cloudformation:stack-name"
+
+// GetStackNameFromVpc returns the name of the cloudformation stack that created the vpc
+func GetStackNameFromVpc(ec2_client_p VpcDescriber, vpcId string) (string, error) {
+	input := ec2.DescribeVpcsInput{VpcIds: []string{vpcId}}
+	output, err := ec2_client_p.DescribeVpcs(context.TODO(), &input)
+	if err != nil {
+		return "", fmt.Errorf("describing vpc %s: %w", vpcId, err)
+	}
+	if len(output.Vpcs) == 0 {
+		return "", fmt.Errorf("%w: vpc %s", ErrResourceNotFound, vpcId)
+	}
+	for _, tag := range output.Vpcs[0].Tags {
+		if *tag.Key == stackNameTagKey {
+			return *tag.Value, nil
+		}
+	}
+	return "", fmt.Errorf("%w: vpc %s has no %s tag", ErrStackNotFound, vpcId, stackNameTagKey)
+}
+
+// StackArn is the parts of a stack id, arn:aws:cloudformation:<region>:<account>:stack/<name>/<uuid>
+type StackArn struct {
+	Region    string
+	AccountId string
+	StackName string
+}
+
+// ParseStackArn splits a stack id into its parts, ok is false when the value isn't a stack arn, ex. a stack name
+func ParseStackArn(value string) (StackArn, bool) {
+	parsed, err := arn.Parse(value)
+	if err != nil || parsed.Service != "cloudformation" {
+		return StackArn{}, false
+	}
+	resource := strings.Split(parsed.Resource, "/")
+	if len(resource) < 2 || resource[0] != "stack" {
+		return StackArn{}, false
+	}
+	return StackArn{Region: parsed.Region, AccountId: parsed.AccountID, StackName: resource[1]}, true
 }
 
-func GetResolverRuleAssociation(route53resolver_client_p *route53resolver.Client, vpcId string, resolver_rule_name string) *route53resolver_types.ResolverRuleAssociation {
-	resolverRuleId := getResolverRuleId(route53resolver_client_p, resolver_rule_name)
+// stack statuses no operation is in progress or failed part way in
+var stableStackStatuses = map[cfn_types.StackStatus]bool{
+	cfn_types.StackStatusCreateComplete:         true,
+	cfn_types.StackStatusUpdateComplete:         true,
+	cfn_types.StackStatusUpdateRollbackComplete: true,
+	cfn_types.StackStatusImportComplete:         true,
+	cfn_types.StackStatusImportRollbackComplete: true,
+}
+
+// IsStableStackStatus is true when nothing is changing the stack and its last operation finished cleanly
+func IsStableStackStatus(status cfn_types.StackStatus) bool {
+	return stableStackStatuses[status]
+}
+
+// ListStackNames returns the names of every stack that isn't deleted and whose name matches pattern
+func ListStackNames(cfn_client_p StackDescriber, pattern *regexp.Regexp) ([]string, error) {
+	stackNames := []string{}
+	input := cfn.ListStacksInput{}
+	for {
+		output, err := cfn_client_p.ListStacks(context.TODO(), &input)
+		if err != nil {
+			return nil, fmt.Errorf("listing stacks: %w", err)
+		}
+		for _, summary := range output.StackSummaries {
+			if summary.StackStatus != cfn_types.StackStatusDeleteComplete && pattern.MatchString(*summary.StackName) {
+				stackNames = append(stackNames, *summary.StackName)
+			}
+		}
+		if output.NextToken == nil {
+			return stackNames, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// StackResourcesByType returns the stack's resources of the cloudformation resource type ordered by logical id,
+// numbers in the logical id compared by value so Subnet2 comes before Subnet10
+func StackResourcesByType(stackResourcesOutput_p cfn.DescribeStackResourcesOutput, resourceType string) []cfn_types.StackResource {
+	resources := Filter(stackResourcesOutput_p.StackResources, func(resource cfn_types.StackResource) bool {
+		return aws.ToString(resource.ResourceType) == resourceType
+	})
+	sort.SliceStable(resources, func(i, j int) bool {
+		return naturalLess(aws.ToString(resources[i].LogicalResourceId), aws.ToString(resources[j].LogicalResourceId))
+	})
+	return resources
+}
+
+func naturalLess(a string, b string) bool {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			aNumber, bNumber := strings.TrimLeft(aDigits, "0"), strings.TrimLeft(bDigits, "0")
+			if len(aNumber) != len(bNumber) {
+				return len(aNumber) < len(bNumber)
+			}
+			if aNumber != bNumber {
+				return aNumber < bNumber
+			}
+			a, b = a[len(aDigits):], b[len(bDigits):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(value string) string {
+	i := 0
+	for i < len(value) && value[i] >= '0' && value[i] <= '9' {
+		i++
+	}
+	return value[:i]
+}
+
+// ListImportingStacks returns the names of the stacks that Fn::ImportValue the export
+func ListImportingStacks(cfn_client_p StackDescriber, exportName string) ([]string, error) {
+	stackNames := []string{}
+	input := cfn.ListImportsInput{ExportName: &exportName}
+	for {
+		output, err := cfn_client_p.ListImports(context.TODO(), &input)
+		if isExportNotImported(err) {
+			return stackNames, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing imports of export %s: %w", exportName, err)
+		}
+		stackNames = append(stackNames, output.Imports...)
+		if output.NextToken == nil {
+			return stackNames, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// GetResolverRuleAssociation returns the vpc's association with the named resolver rule, or nil if the rule
+// exists but isn't associated with the vpc
+func GetResolverRuleAssociation(route53resolver_client_p ResolverRuleLister, vpcId string, resolver_rule_name string) (*route53resolver_types.ResolverRuleAssociation, error) {
+	resolverRuleId, err := getResolverRuleId(route53resolver_client_p, resolver_rule_name)
+	if err != nil {
+		return nil, err
+	}
 	resolverRuleIdFilterName := "ResolverRuleId"
 	vpcIdFilterName := "VPCId"
 	filters := []route53resolver_types.Filter{
@@ -57,17 +293,45 @@ func GetResolverRuleAssociation(route53resolver_client_p *route53resolver.Client
 	}
 	input := route53resolver.ListResolverRuleAssociationsInput{Filters: filters}
 	output, err := route53resolver_client_p.ListResolverRuleAssociations(context.TODO(), &input)
-	Check(err)
+	if err != nil {
+		return nil, fmt.Errorf("listing associations of resolver rule %s: %w", resolver_rule_name, err)
+	}
 
 	if len(output.ResolverRuleAssociations) > 0 {
 		rule := output.ResolverRuleAssociations[0]
-		return &rule
+		return &rule, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
-func getResolverRuleId(route53resolver_client_p *route53resolver.Client, resolver_rule_name string) string {
+// GetFirstResolverRuleAssociation tries each resolver rule name in order, so an iac managed rule can be preferred
+// over its legacy equivalent. Rules missing from the account are skipped, ErrResolverRuleMissing is only returned
+// when none of them exist
+func GetFirstResolverRuleAssociation(route53resolver_client_p ResolverRuleLister, vpcId string, resolver_rule_names ...string) (*route53resolver_types.ResolverRuleAssociation, error) {
+	var missingErr error
+	ruleFound := false
+	for _, resolver_rule_name := range resolver_rule_names {
+		assoc, err := GetResolverRuleAssociation(route53resolver_client_p, vpcId, resolver_rule_name)
+		if errors.Is(err, ErrResolverRuleMissing) {
+			missingErr = errors.Join(missingErr, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if assoc != nil {
+			return assoc, nil
+		}
+		ruleFound = true
+	}
+	if ruleFound {
+		return nil, nil
+	}
+	return nil, missingErr
+}
+
+func getResolverRuleId(route53resolver_client_p ResolverRuleLister, resolver_rule_name string) (string, error) {
 	nameFilterName := "Name"
 	filters := []route53resolver_types.Filter{
 		{
@@ -77,14 +341,13 @@ func getResolverRuleId(route53resolver_client_p *route53resolver.Client, resolve
 	}
 	input := route53resolver.ListResolverRulesInput{Filters: filters}
 	output, err := route53resolver_client_p.ListResolverRules(context.TODO(), &input)
-	Check(err)
-	return *output.ResolverRules[0].Id
-}
-
-func Check(e error) {
-	if e != nil {
-		log.Fatal(e)
+	if err != nil {
+		return "", fmt.Errorf("listing resolver rules named %s: %w", resolver_rule_name, err)
 	}
+	if len(output.ResolverRules) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrResolverRuleMissing, resolver_rule_name)
+	}
+	return *output.ResolverRules[0].Id, nil
 }
 
 // generic Filter function type
@@ -102,20 +365,35 @@ func Filter[A any](input []A, f filterFunc[A]) []A {
 }
 
 func GetParameterValue(stackResourcesOutput_p cfn.DescribeStacksOutput,
-	paramKey string) string {
-	return *GetParameter(stackResourcesOutput_p, paramKey).ParameterValue
+	paramKey string) (string, error) {
+	param, err := GetParameter(stackResourcesOutput_p, paramKey)
+	if err != nil {
+		return "", err
+	}
+	return *param.ParameterValue, nil
 }
 
 func GetParameterResolvedValue(stackResourcesOutput_p cfn.DescribeStacksOutput,
-	paramKey string) string {
-	return *GetParameter(stackResourcesOutput_p, paramKey).ResolvedValue
+	paramKey string) (string, error) {
+	param, err := GetParameter(stackResourcesOutput_p, paramKey)
+	if err != nil {
+		return "", err
+	}
+	if param.ResolvedValue == nil {
+		return "", fmt.Errorf("%w: %s has no resolved value", ErrParameterNotFound, paramKey)
+	}
+	return *param.ResolvedValue, nil
 }
 
 func GetParameter(stacksOutput_p cfn.DescribeStacksOutput,
-	paramKey string) cfn_types.Parameter {
+	paramKey string) (cfn_types.Parameter, error) {
 	params := stacksOutput_p.Stacks[0].Parameters
 	f := func(param cfn_types.Parameter) bool {
 		return *param.ParameterKey == paramKey
 	}
-	return Filter(params, f)[0]
+	matches := Filter(params, f)
+	if len(matches) == 0 {
+		return cfn_types.Parameter{}, fmt.Errorf("%w: %s", ErrParameterNotFound, paramKey)
+	}
+	return matches[0], nil
 }
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/smithy-go"
+)
+
+// sentinel errors, wrapped with the details of the failing call - check for them with errors.Is
+var (
+	ErrStackNotFound       = errors.New("stack not found")
+	ErrParameterNotFound   = errors.New("stack parameter not found")
+	ErrResourceNotFound    = errors.New("aws resource not found")
+	ErrResolverRuleMissing = errors.New("resolver rule not found")
+	ErrCriticalReplacement = errors.New("plan replaces or destroys a critical resource")
+	ErrStackUnstable       = errors.New("stack is not in a stable status")
+	ErrStackDrifted        = errors.New("stack resources have drifted")
+)
+
+// ErrImportFailed is returned for each terraform address that could not be imported - check for it with errors.As
+type ErrImportFailed struct {
+	Address  string
+	ImportId string
+	Err      error
+}
+
+func (e *ErrImportFailed) Error() string {
+	return fmt.Sprintf("importing %s to %s: %s", e.ImportId, e.Address, e.Err)
+}
+
+func (e *ErrImportFailed) Unwrap() error {
+	return e.Err
+}
+
+// cloudformation reports a missing stack as a generic ValidationError
+func isStackNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) &&
+		apiErr.ErrorCode() == "ValidationError" &&
+		strings.Contains(apiErr.ErrorMessage(), "does not exist")
+}
+
+// cloudformation reports an export no stack imports as a ValidationError rather than an empty list
+func isExportNotImported(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) &&
+		apiErr.ErrorCode() == "ValidationError" &&
+		strings.Contains(apiErr.ErrorMessage(), "is not imported by any stack")
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"vpc-import-cli/tf_import"
+)
+
+// runConverge iterates plan/tfvars rewrites after --genvars and --import until the plan is clean
+func runConverge(args []string) {
+	flags := flag.NewFlagSet("converge", flag.ExitOnError)
+	maxRounds_p := flags.Int("max-rounds", 5, "Number of plan/rewrite rounds to run before giving up")
+	dryRun_p := flags.Bool("dry-run", false, "Boolean flag, set to print the tfvars changes the first plan suggests without writing them")
+	flags.Parse(args)
+
+	if *maxRounds_p < 1 {
+		usageError(flags, "--max-rounds must be at least 1")
+	}
+
+	result, err := tf_import.TerraformConverge(".", *maxRounds_p, *dryRun_p)
+	if result.Rounds > 0 {
+		tf_import.PrintConvergeResult(os.Stdout, result)
+	}
+	exitOnError(err)
+}
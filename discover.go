@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"regexp"
+
+	"vpc-import-cli/discover"
+	"vpc-import-cli/tf_import"
+)
+
+// runDiscover lists the networking-dedicated-spoke stacks in an account with a readiness score, to plan
+// migration waves
+func runDiscover(args []string) {
+	flags := flag.NewFlagSet("discover", flag.ExitOnError)
+	stackPattern_p := flags.String("stack-pattern", "", "Regular expression, set to only consider stacks whose name matches")
+	output_p := flags.String("output", "table", "Output format, table or json")
+	mappingPath_p := flags.String("mapping", "", "Path to a YAML or JSON mapping file, the logical ids it maps are checked for in each stack")
+	region_p := flags.String("region", "", "AWS region to discover stacks in, defaults to the region in the shared aws config")
+	roleArn_p := flags.String("role-arn", "", "ARN of a role to assume, ex. in the account to discover stacks in")
+	flags.Parse(args)
+
+	if *output_p != "table" && *output_p != "json" {
+		usageError(flags, "--output must be table or json")
+	}
+	pattern, err := regexp.Compile(*stackPattern_p)
+	if err != nil {
+		usageError(flags, "--stack-pattern: "+err.Error())
+	}
+
+	mapping, err := tf_import.LoadMapping(*mappingPath_p)
+	exitOnError(err)
+	cfn_client_p, ec2_client_p, route53resolver_client_p, err := newClients("", *region_p, *roleArn_p)
+	exitOnError(err)
+
+	candidates, err := discover.Discover(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, pattern)
+	exitOnError(err)
+	if *output_p == "json" {
+		exitOnError(discover.PrintCandidatesJson(os.Stdout, candidates))
+	} else {
+		discover.PrintCandidates(os.Stdout, candidates)
+	}
+}
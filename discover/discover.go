@@ -0,0 +1,230 @@
+// Package discover finds networking-dedicated-spoke stacks in an account and scores how ready each is to import
+package discover
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfn_types "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2_types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"vpc-import-cli/common"
+	"vpc-import-cli/tf_import"
+)
+
+// a stack with all of these parameters is taken to be a networking-dedicated-spoke stack
+var spokeParameters = []string{"IpRange", "TgwRouteTableID", "SubnetCidrBits", "TransitGatewayID"}
+
+// the resolver rules genvars looks up, each group is satisfied by any one of its rules
+var resolverRuleGroups = [][]string{
+	{common.ResolverRuleInternet},
+	{common.ResolverRuleTldIac, common.ResolverRuleTldLegacy},
+	{common.ResolverRuleCrossVpcIac, common.ResolverRuleCrossVpcLegacy},
+}
+
+// Candidate is a spoke stack and the result of each readiness check. Score is out of 100, 25 for each of:
+// a stable stack status, the mapped logical ids present (pro rata), the resolver rules found and the tgw
+// attachment available
+type Candidate struct {
+	StackName            string   `json:"stack_name"`
+	StackStatus          string   `json:"stack_status"`
+	VpcId                string   `json:"vpc_id"`
+	IpRange              string   `json:"ip_range"`
+	Score                int      `json:"score"`
+	StableStatus         bool     `json:"stable_status"`
+	MissingLogicalIds    []string `json:"missing_logical_ids"`
+	MissingResolverRules []string `json:"missing_resolver_rules"`
+	TgwAttachmentState   string   `json:"tgw_attachment_state"`
+	Error                string   `json:"error,omitempty"`
+}
+
+// Discover returns a candidate for every stack whose name matches pattern and that has the spoke parameters,
+// most ready first
+func Discover(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping tf_import.Mapping,
+	pattern *regexp.Regexp) ([]Candidate, error) {
+
+	stackNames, err := common.ListStackNames(cfn_client_p, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []Candidate{}
+	for _, stackName := range stackNames {
+		stacksOutput_p, err := common.GetStacksOutput(cfn_client_p, &stackName)
+		if err != nil {
+			return nil, err
+		}
+		stack := stacksOutput_p.Stacks[0]
+		if !isSpokeStack(stack) {
+			continue
+		}
+		candidate := Candidate{
+			StackName:    stackName,
+			StackStatus:  string(stack.StackStatus),
+			IpRange:      parameterValue(stack, "IpRange"),
+			StableStatus: common.IsStableStackStatus(stack.StackStatus),
+		}
+		if err = checkReadiness(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, &candidate); err != nil {
+			candidate.Error = err.Error()
+		}
+		candidate.Score = score(candidate, mapping)
+		candidates = append(candidates, candidate)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].StackName < candidates[j].StackName
+	})
+	return candidates, nil
+}
+
+func isSpokeStack(stack cfn_types.Stack) bool {
+	for _, name := range spokeParameters {
+		found := false
+		for _, param := range stack.Parameters {
+			if aws.ToString(param.ParameterKey) == name {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func parameterValue(stack cfn_types.Stack, name string) string {
+	for _, param := range stack.Parameters {
+		if aws.ToString(param.ParameterKey) == name {
+			return aws.ToString(param.ParameterValue)
+		}
+	}
+	return ""
+}
+
+// checkReadiness fills in the candidate's missing logical ids, resolver rules and tgw attachment state
+func checkReadiness(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping tf_import.Mapping,
+	candidate *Candidate) error {
+
+	stackResourcesOutput_p, err := common.GetStackResourcesOutput(cfn_client_p, &candidate.StackName)
+	if err != nil {
+		return err
+	}
+	physicalIds := map[string]string{}
+	for _, resource := range stackResourcesOutput_p.StackResources {
+		physicalIds[aws.ToString(resource.LogicalResourceId)] = aws.ToString(resource.PhysicalResourceId)
+	}
+
+	candidate.MissingLogicalIds = []string{}
+	for _, resource := range mapping.Resources {
+		if _, ok := physicalIds[resource.LogicalId]; resource.LogicalId != "" && !ok {
+			candidate.MissingLogicalIds = append(candidate.MissingLogicalIds, resource.LogicalId)
+		}
+	}
+
+	candidate.VpcId = physicalIds["VPC"]
+	candidate.MissingResolverRules = []string{}
+	for _, names := range resolverRuleGroups {
+		assoc, err := common.GetFirstResolverRuleAssociation(route53resolver_client_p, candidate.VpcId, names...)
+		if err != nil && !errors.Is(err, common.ErrResolverRuleMissing) {
+			return err
+		}
+		if assoc == nil {
+			candidate.MissingResolverRules = append(candidate.MissingResolverRules, strings.Join(names, " or "))
+		}
+	}
+
+	candidate.TgwAttachmentState = "missing"
+	if tgwAttachmentId, ok := physicalIds["TgwAttach"]; ok {
+		filterName := "transit-gateway-attachment-id"
+		input := ec2.DescribeTransitGatewayVpcAttachmentsInput{Filters: []ec2_types.Filter{{Name: &filterName, Values: []string{tgwAttachmentId}}}}
+		output, err := ec2_client_p.DescribeTransitGatewayVpcAttachments(context.TODO(), &input)
+		if err != nil {
+			return fmt.Errorf("describing transit gateway attachment %s: %w", tgwAttachmentId, err)
+		}
+		if len(output.TransitGatewayVpcAttachments) > 0 {
+			candidate.TgwAttachmentState = string(output.TransitGatewayVpcAttachments[0].State)
+		}
+	}
+	return nil
+}
+
+func score(candidate Candidate, mapping tf_import.Mapping) int {
+	if candidate.Error != "" {
+		return 0
+	}
+	score := 0
+	if candidate.StableStatus {
+		score += 25
+	}
+	mappedCount := 0
+	for _, resource := range mapping.Resources {
+		if resource.LogicalId != "" {
+			mappedCount++
+		}
+	}
+	if mappedCount > 0 {
+		score += 25 * (mappedCount - len(candidate.MissingLogicalIds)) / mappedCount
+	}
+	if len(candidate.MissingResolverRules) == 0 {
+		score += 25
+	}
+	if candidate.TgwAttachmentState == string(ec2_types.TransitGatewayAttachmentStateAvailable) {
+		score += 25
+	}
+	return score
+}
+
+// PrintCandidates writes the candidates as a table
+func PrintCandidates(out io.Writer, candidates []Candidate) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCORE\tSTACK\tSTATUS\tVPC ID\tIP RANGE\tMISSING LOGICAL IDS\tMISSING RESOLVER RULES\tTGW ATTACHMENT\tERROR\t")
+	for _, candidate := range candidates {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			candidate.Score,
+			candidate.StackName,
+			candidate.StackStatus,
+			dashIfEmpty(candidate.VpcId),
+			dashIfEmpty(candidate.IpRange),
+			dashIfEmpty(strings.Join(candidate.MissingLogicalIds, ", ")),
+			dashIfEmpty(strings.Join(candidate.MissingResolverRules, ", ")),
+			candidate.TgwAttachmentState,
+			dashIfEmpty(candidate.Error))
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d candidate stacks\n", len(candidates))
+}
+
+// PrintCandidatesJson writes the candidates as a JSON array
+func PrintCandidatesJson(out io.Writer, candidates []Candidate) error {
+	candidatesJson, err := json.MarshalIndent(candidates, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(candidatesJson))
+	return err
+}
+
+func dashIfEmpty(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"vpc-import-cli/tf_import"
+)
+
+// runExports reports the stack's exports and the stacks importing them, and writes terraform to replace them
+func runExports(args []string) {
+	flags := flag.NewFlagSet("exports", flag.ExitOnError)
+	stackName_p := flags.String("stack-name", "", "The StackName or stack ARN of the networking-dedicated-spoke stack whose exports to list")
+	mappingPath_p := flags.String("mapping", "", "Path to a YAML or JSON mapping file, used to find the terraform address of each exported id")
+	format_p := flags.String("format", tf_import.ExportFormatOutput, "What replaces each export with --out, "+tf_import.ExportFormatOutput+" blocks or "+tf_import.ExportFormatSsm+" parameters")
+	ssmPrefix_p := flags.String("ssm-prefix", "/cloudformation-exports/", "Prefix of the ssm parameter names with --format "+tf_import.ExportFormatSsm+", followed by the export name")
+	out_p := flags.String("out", "", "Path to write the terraform replacing the exports to, ex. modules/vpc/exports.tf")
+	region_p := flags.String("region", "", "AWS region of the stack, defaults to the region in the shared aws config")
+	roleArn_p := flags.String("role-arn", "", "ARN of a role to assume to read the stack")
+	fromSnapshot_p := flags.String("from-snapshot", "", "Path to an archive written by the snapshot command, set to read the stack from it instead of aws")
+	flags.Parse(args)
+
+	if *stackName_p == "" {
+		usageError(flags, "value for '--stack-name' flag is required")
+	}
+	parseStackArnFlag(flags, stackName_p, region_p, *roleArn_p, *fromSnapshot_p)
+	if *format_p != tf_import.ExportFormatOutput && *format_p != tf_import.ExportFormatSsm {
+		usageError(flags, "--format must be "+tf_import.ExportFormatOutput+" or "+tf_import.ExportFormatSsm)
+	}
+
+	mapping, err := tf_import.LoadMapping(*mappingPath_p)
+	exitOnError(err)
+	cfn_client_p, ec2_client_p, route53resolver_client_p, err := newClients(*fromSnapshot_p, *region_p, *roleArn_p)
+	exitOnError(err)
+
+	exports, err := tf_import.ListStackExports(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p)
+	exitOnError(err)
+	tf_import.PrintExports(os.Stdout, exports)
+	if *out_p != "" {
+		exitOnError(tf_import.WriteExports(*out_p, exports, *format_p, *ssmPrefix_p))
+	}
+}
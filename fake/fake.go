@@ -0,0 +1,209 @@
+// Package fake implements the common client interfaces by replaying aws responses recorded in a JSON fixture,
+// so genvars and the import mapping can run without aws credentials
+package fake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
+	"github.com/aws/smithy-go"
+
+	"vpc-import-cli/common"
+)
+
+var (
+	_ common.StackDescriber     = (*Client)(nil)
+	_ common.StackRetirer       = (*Client)(nil)
+	_ common.VpcDescriber       = (*Client)(nil)
+	_ common.ResolverRuleLister = (*Client)(nil)
+)
+
+// Exchange is a single recorded aws call. Input and Output are the JSON encoding of the sdk's input and output
+// structs for the operation, Error is set instead of Output for calls that failed
+type Exchange struct {
+	Input  json.RawMessage `json:"input"`
+	Output json.RawMessage `json:"output,omitempty"`
+	Error  *APIError       `json:"error,omitempty"`
+}
+
+// APIError is a recorded aws api error, ex. the ValidationError returned for a stack that doesn't exist
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Fixture is the recorded exchanges keyed by operation name, ex. DescribeStacks
+type Fixture map[string][]Exchange
+
+// Client replays a Fixture, it implements common.StackDescriber, common.StackRetirer, common.VpcDescriber
+// and common.ResolverRuleLister
+type Client struct {
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+func New(fixture Fixture) *Client {
+	if fixture == nil {
+		fixture = Fixture{}
+	}
+	return &Client{fixture: fixture}
+}
+
+// Load reads a JSON fixture file
+func Load(path string) (*Client, error) {
+	fixtureJson, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	client, err := Parse(fixtureJson)
+	if err != nil {
+		return nil, fmt.Errorf("parsing fixture %s: %w", path, err)
+	}
+	return client, nil
+}
+
+// Parse replays a JSON fixture, ex. one a test edited after reading it
+func Parse(fixtureJson []byte) (*Client, error) {
+	var fixture Fixture
+	if err := json.Unmarshal(fixtureJson, &fixture); err != nil {
+		return nil, err
+	}
+	return New(fixture), nil
+}
+
+// replay returns the recorded output for the first exchange of the operation whose input matches.
+// Recorded inputs are round tripped through the input type so fixtures can leave out nil fields
+func replay[I any, O any](c *Client, operation string, input *I) (*O, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	want, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, exchange := range c.fixture[operation] {
+		var recorded I
+		if err = json.Unmarshal(exchange.Input, &recorded); err != nil {
+			return nil, fmt.Errorf("fake: parsing recorded %s input: %w", operation, err)
+		}
+		got, err := json.Marshal(&recorded)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(got, want) {
+			continue
+		}
+		if exchange.Error != nil {
+			return nil, &smithy.GenericAPIError{Code: exchange.Error.Code, Message: exchange.Error.Message}
+		}
+		var output O
+		if err = json.Unmarshal(exchange.Output, &output); err != nil {
+			return nil, fmt.Errorf("fake: parsing recorded %s output: %w", operation, err)
+		}
+		return &output, nil
+	}
+	return nil, fmt.Errorf("fake: no recorded %s response for input %s", operation, want)
+}
+
+func (c *Client) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	return replay[cloudformation.DescribeStacksInput, cloudformation.DescribeStacksOutput](c, "DescribeStacks", params)
+}
+
+func (c *Client) DescribeStackResources(ctx context.Context, params *cloudformation.DescribeStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourcesOutput, error) {
+	return replay[cloudformation.DescribeStackResourcesInput, cloudformation.DescribeStackResourcesOutput](c, "DescribeStackResources", params)
+}
+
+func (c *Client) ListStacks(ctx context.Context, params *cloudformation.ListStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListStacksOutput, error) {
+	return replay[cloudformation.ListStacksInput, cloudformation.ListStacksOutput](c, "ListStacks", params)
+}
+
+func (c *Client) DetectStackDrift(ctx context.Context, params *cloudformation.DetectStackDriftInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DetectStackDriftOutput, error) {
+	return replay[cloudformation.DetectStackDriftInput, cloudformation.DetectStackDriftOutput](c, "DetectStackDrift", params)
+}
+
+func (c *Client) DescribeStackDriftDetectionStatus(ctx context.Context, params *cloudformation.DescribeStackDriftDetectionStatusInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	return replay[cloudformation.DescribeStackDriftDetectionStatusInput, cloudformation.DescribeStackDriftDetectionStatusOutput](c, "DescribeStackDriftDetectionStatus", params)
+}
+
+func (c *Client) DescribeStackResourceDrifts(ctx context.Context, params *cloudformation.DescribeStackResourceDriftsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceDriftsOutput, error) {
+	return replay[cloudformation.DescribeStackResourceDriftsInput, cloudformation.DescribeStackResourceDriftsOutput](c, "DescribeStackResourceDrifts", params)
+}
+
+func (c *Client) ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error) {
+	return replay[cloudformation.ListImportsInput, cloudformation.ListImportsOutput](c, "ListImports", params)
+}
+
+func (c *Client) GetTemplate(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error) {
+	return replay[cloudformation.GetTemplateInput, cloudformation.GetTemplateOutput](c, "GetTemplate", params)
+}
+
+func (c *Client) CreateChangeSet(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error) {
+	return replay[cloudformation.CreateChangeSetInput, cloudformation.CreateChangeSetOutput](c, "CreateChangeSet", params)
+}
+
+func (c *Client) DescribeChangeSet(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error) {
+	return replay[cloudformation.DescribeChangeSetInput, cloudformation.DescribeChangeSetOutput](c, "DescribeChangeSet", params)
+}
+
+func (c *Client) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
+	return replay[cloudformation.ExecuteChangeSetInput, cloudformation.ExecuteChangeSetOutput](c, "ExecuteChangeSet", params)
+}
+
+func (c *Client) DeleteChangeSet(ctx context.Context, params *cloudformation.DeleteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error) {
+	return replay[cloudformation.DeleteChangeSetInput, cloudformation.DeleteChangeSetOutput](c, "DeleteChangeSet", params)
+}
+
+func (c *Client) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	return replay[cloudformation.DeleteStackInput, cloudformation.DeleteStackOutput](c, "DeleteStack", params)
+}
+
+func (c *Client) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	return replay[ec2.DescribeVpcsInput, ec2.DescribeVpcsOutput](c, "DescribeVpcs", params)
+}
+
+func (c *Client) DescribeDhcpOptions(ctx context.Context, params *ec2.DescribeDhcpOptionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeDhcpOptionsOutput, error) {
+	return replay[ec2.DescribeDhcpOptionsInput, ec2.DescribeDhcpOptionsOutput](c, "DescribeDhcpOptions", params)
+}
+
+func (c *Client) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	return replay[ec2.DescribeSubnetsInput, ec2.DescribeSubnetsOutput](c, "DescribeSubnets", params)
+}
+
+func (c *Client) DescribeSecurityGroupRules(ctx context.Context, params *ec2.DescribeSecurityGroupRulesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupRulesOutput, error) {
+	return replay[ec2.DescribeSecurityGroupRulesInput, ec2.DescribeSecurityGroupRulesOutput](c, "DescribeSecurityGroupRules", params)
+}
+
+func (c *Client) DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error) {
+	return replay[ec2.DescribeNetworkAclsInput, ec2.DescribeNetworkAclsOutput](c, "DescribeNetworkAcls", params)
+}
+
+func (c *Client) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	return replay[ec2.DescribeRouteTablesInput, ec2.DescribeRouteTablesOutput](c, "DescribeRouteTables", params)
+}
+
+func (c *Client) DescribeTransitGatewayVpcAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayVpcAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error) {
+	return replay[ec2.DescribeTransitGatewayVpcAttachmentsInput, ec2.DescribeTransitGatewayVpcAttachmentsOutput](c, "DescribeTransitGatewayVpcAttachments", params)
+}
+
+func (c *Client) DescribeTransitGatewayAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayAttachmentsOutput, error) {
+	return replay[ec2.DescribeTransitGatewayAttachmentsInput, ec2.DescribeTransitGatewayAttachmentsOutput](c, "DescribeTransitGatewayAttachments", params)
+}
+
+func (c *Client) GetTransitGatewayAttachmentPropagations(ctx context.Context, params *ec2.GetTransitGatewayAttachmentPropagationsInput, optFns ...func(*ec2.Options)) (*ec2.GetTransitGatewayAttachmentPropagationsOutput, error) {
+	return replay[ec2.GetTransitGatewayAttachmentPropagationsInput, ec2.GetTransitGatewayAttachmentPropagationsOutput](c, "GetTransitGatewayAttachmentPropagations", params)
+}
+
+func (c *Client) ListResolverRules(ctx context.Context, params *route53resolver.ListResolverRulesInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRulesOutput, error) {
+	return replay[route53resolver.ListResolverRulesInput, route53resolver.ListResolverRulesOutput](c, "ListResolverRules", params)
+}
+
+func (c *Client) ListResolverRuleAssociations(ctx context.Context, params *route53resolver.ListResolverRuleAssociationsInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRuleAssociationsOutput, error) {
+	return replay[route53resolver.ListResolverRuleAssociationsInput, route53resolver.ListResolverRuleAssociationsOutput](c, "ListResolverRuleAssociations", params)
+}
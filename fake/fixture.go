@@ -0,0 +1,25 @@
+package fake
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// LoadFixture replays the recorded networking-dedicated-spoke stack in testdata for a test, with each old, new
+// pair of replacements applied to the fixture first, ex. to make a stack parameter stale
+func LoadFixture(t testing.TB, replacements ...string) *Client {
+	t.Helper()
+	_, file, _, _ := runtime.Caller(0)
+	fixtureJson, err := os.ReadFile(filepath.Join(filepath.Dir(file), "testdata", "networking-dedicated-spoke.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := Parse([]byte(strings.NewReplacer(replacements...).Replace(string(fixtureJson))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
@@ -0,0 +1,198 @@
+package fake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
+	"github.com/aws/smithy-go"
+
+	"vpc-import-cli/common"
+)
+
+var (
+	_ common.StackDescriber     = (*Recorder)(nil)
+	_ common.VpcDescriber       = (*Recorder)(nil)
+	_ common.ResolverRuleLister = (*Recorder)(nil)
+)
+
+// Recorder passes every call through to the real aws clients and records the exchange, so the fixture
+// it saves can be replayed later by Client, ex. on a machine without aws credentials
+type Recorder struct {
+	cfn_client_p             common.StackDescriber
+	ec2_client_p             common.VpcDescriber
+	route53resolver_client_p common.ResolverRuleLister
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+func NewRecorder(cfn_client_p common.StackDescriber, ec2_client_p common.VpcDescriber, route53resolver_client_p common.ResolverRuleLister) *Recorder {
+	return &Recorder{
+		cfn_client_p:             cfn_client_p,
+		ec2_client_p:             ec2_client_p,
+		route53resolver_client_p: route53resolver_client_p,
+		fixture:                  Fixture{},
+	}
+}
+
+// Fixture returns everything recorded so far
+func (r *Recorder) Fixture() Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fixture
+}
+
+// Save writes everything recorded so far to a JSON file
+func (r *Recorder) Save(path string) error {
+	fixtureJson, err := json.MarshalIndent(r.Fixture(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, fixtureJson, 0644)
+}
+
+// record makes the call and stores the exchange - aws api errors are recorded too so they replay the same way,
+// other errors (ex. network failures) aren't
+func record[I any, O any](r *Recorder, operation string, input *I, call func() (*O, error)) (*O, error) {
+	output, callErr := call()
+
+	exchange := Exchange{}
+	var apiErr smithy.APIError
+	var err error
+	if errors.As(callErr, &apiErr) {
+		exchange.Error = &APIError{Code: apiErr.ErrorCode(), Message: apiErr.ErrorMessage()}
+	} else if callErr != nil {
+		return output, callErr
+	} else if exchange.Output, err = json.Marshal(output); err != nil {
+		return nil, err
+	}
+	if exchange.Input, err = json.Marshal(input); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// the same call is made more than once in a run, ex. describing the vpc, only the first is kept
+	for _, recorded := range r.fixture[operation] {
+		if bytes.Equal(recorded.Input, exchange.Input) {
+			return output, callErr
+		}
+	}
+	r.fixture[operation] = append(r.fixture[operation], exchange)
+	return output, callErr
+}
+
+func (r *Recorder) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	return record(r, "DescribeStacks", params, func() (*cloudformation.DescribeStacksOutput, error) {
+		return r.cfn_client_p.DescribeStacks(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeStackResources(ctx context.Context, params *cloudformation.DescribeStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourcesOutput, error) {
+	return record(r, "DescribeStackResources", params, func() (*cloudformation.DescribeStackResourcesOutput, error) {
+		return r.cfn_client_p.DescribeStackResources(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) ListStacks(ctx context.Context, params *cloudformation.ListStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListStacksOutput, error) {
+	return record(r, "ListStacks", params, func() (*cloudformation.ListStacksOutput, error) {
+		return r.cfn_client_p.ListStacks(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DetectStackDrift(ctx context.Context, params *cloudformation.DetectStackDriftInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DetectStackDriftOutput, error) {
+	return record(r, "DetectStackDrift", params, func() (*cloudformation.DetectStackDriftOutput, error) {
+		return r.cfn_client_p.DetectStackDrift(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeStackDriftDetectionStatus(ctx context.Context, params *cloudformation.DescribeStackDriftDetectionStatusInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	return record(r, "DescribeStackDriftDetectionStatus", params, func() (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+		return r.cfn_client_p.DescribeStackDriftDetectionStatus(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeStackResourceDrifts(ctx context.Context, params *cloudformation.DescribeStackResourceDriftsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceDriftsOutput, error) {
+	return record(r, "DescribeStackResourceDrifts", params, func() (*cloudformation.DescribeStackResourceDriftsOutput, error) {
+		return r.cfn_client_p.DescribeStackResourceDrifts(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error) {
+	return record(r, "ListImports", params, func() (*cloudformation.ListImportsOutput, error) {
+		return r.cfn_client_p.ListImports(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	return record(r, "DescribeVpcs", params, func() (*ec2.DescribeVpcsOutput, error) {
+		return r.ec2_client_p.DescribeVpcs(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeDhcpOptions(ctx context.Context, params *ec2.DescribeDhcpOptionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeDhcpOptionsOutput, error) {
+	return record(r, "DescribeDhcpOptions", params, func() (*ec2.DescribeDhcpOptionsOutput, error) {
+		return r.ec2_client_p.DescribeDhcpOptions(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	return record(r, "DescribeSubnets", params, func() (*ec2.DescribeSubnetsOutput, error) {
+		return r.ec2_client_p.DescribeSubnets(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeSecurityGroupRules(ctx context.Context, params *ec2.DescribeSecurityGroupRulesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupRulesOutput, error) {
+	return record(r, "DescribeSecurityGroupRules", params, func() (*ec2.DescribeSecurityGroupRulesOutput, error) {
+		return r.ec2_client_p.DescribeSecurityGroupRules(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error) {
+	return record(r, "DescribeNetworkAcls", params, func() (*ec2.DescribeNetworkAclsOutput, error) {
+		return r.ec2_client_p.DescribeNetworkAcls(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	return record(r, "DescribeRouteTables", params, func() (*ec2.DescribeRouteTablesOutput, error) {
+		return r.ec2_client_p.DescribeRouteTables(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeTransitGatewayVpcAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayVpcAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error) {
+	return record(r, "DescribeTransitGatewayVpcAttachments", params, func() (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error) {
+		return r.ec2_client_p.DescribeTransitGatewayVpcAttachments(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) DescribeTransitGatewayAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayAttachmentsOutput, error) {
+	return record(r, "DescribeTransitGatewayAttachments", params, func() (*ec2.DescribeTransitGatewayAttachmentsOutput, error) {
+		return r.ec2_client_p.DescribeTransitGatewayAttachments(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) GetTransitGatewayAttachmentPropagations(ctx context.Context, params *ec2.GetTransitGatewayAttachmentPropagationsInput, optFns ...func(*ec2.Options)) (*ec2.GetTransitGatewayAttachmentPropagationsOutput, error) {
+	return record(r, "GetTransitGatewayAttachmentPropagations", params, func() (*ec2.GetTransitGatewayAttachmentPropagationsOutput, error) {
+		return r.ec2_client_p.GetTransitGatewayAttachmentPropagations(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) ListResolverRules(ctx context.Context, params *route53resolver.ListResolverRulesInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRulesOutput, error) {
+	return record(r, "ListResolverRules", params, func() (*route53resolver.ListResolverRulesOutput, error) {
+		return r.route53resolver_client_p.ListResolverRules(ctx, params, optFns...)
+	})
+}
+
+func (r *Recorder) ListResolverRuleAssociations(ctx context.Context, params *route53resolver.ListResolverRuleAssociationsInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRuleAssociationsOutput, error) {
+	return record(r, "ListResolverRuleAssociations", params, func() (*route53resolver.ListResolverRuleAssociationsOutput, error) {
+		return r.route53resolver_client_p.ListResolverRuleAssociations(ctx, params, optFns...)
+	})
+}
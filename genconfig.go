@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+
+	"vpc-import-cli/tf_import"
+)
+
+// runGenconfig generates HCL for the vpc module from the stack's live resources
+func runGenconfig(args []string) {
+	flags := flag.NewFlagSet("genconfig", flag.ExitOnError)
+	stackName_p := flags.String("stack-name", "", "The StackName or stack ARN of the networking-dedicated-spoke stack to generate the module from")
+	mappingPath_p := flags.String("mapping", "", "Path to a YAML or JSON mapping file of the addresses to generate, defaults to the embedded networking-dedicated-spoke mapping")
+	outDir_p := flags.String("out-dir", "generated/vpc", "Directory to write the generated module's main.tf and variables.tf to")
+	region_p := flags.String("region", "", "AWS region of the stack, defaults to the region in the shared aws config. Also written to a providers_override.tf for terraform")
+	roleArn_p := flags.String("role-arn", "", "ARN of a role to assume to read the stack. Also written to a providers_override.tf for terraform")
+	flags.Parse(args)
+
+	if *stackName_p == "" {
+		usageError(flags, "value for '--stack-name' flag is required")
+	}
+	parseStackArnFlag(flags, stackName_p, region_p, *roleArn_p, "")
+
+	mapping, err := tf_import.LoadMapping(*mappingPath_p)
+	exitOnError(err)
+	cfn_client_p, ec2_client_p, route53resolver_client_p, err := newClients("", *region_p, *roleArn_p)
+	exitOnError(err)
+	exitOnError(writeProviderOverride(".", *region_p, *roleArn_p))
+
+	exitOnError(tf_import.GenerateModuleConfig(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p, ".", *outDir_p))
+}
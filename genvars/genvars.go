@@ -7,28 +7,57 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2_types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
 
 	"vpc-import-cli/common"
 )
 
-func Genvars(cfn_client_p *cloudformation.Client, ec2_client_p *ec2.Client, route53resolver_client_p *route53resolver.Client, stackName_p *string) {
-	stackResourcesOutput_p := common.GetStackResourcesOutput(cfn_client_p, stackName_p)
-	stacksOutput_p := common.GetStacksOutput(cfn_client_p, stackName_p)
+// Genvars writes the stack's terraform.tfvars.json to workingDir
+func Genvars(cfn_client_p common.StackDescriber, ec2_client_p common.VpcDescriber, route53resolver_client_p common.ResolverRuleLister, stackName_p *string, workingDir string) error {
+	tfvars, warnings, err := GenerateTfVars(cfn_client_p, ec2_client_p, route53resolver_client_p, stackName_p)
+	if err != nil {
+		return err
+	}
+	if len(warnings) > 0 {
+		PrintLiveValueWarnings(os.Stderr, warnings)
+	}
+	return writeTfvarsToFile(tfvars, workingDir)
+}
+
+// GenerateTfVars builds the tfvars for the stack without writing them to a file. Values taken from stack
+// parameters are checked against the live resources, the live value is used and a warning returned where they differ
+func GenerateTfVars(cfn_client_p common.StackDescriber, ec2_client_p common.VpcDescriber, route53resolver_client_p common.ResolverRuleLister, stackName_p *string) (TfVars, []LiveValueWarning, error) {
+	stackResourcesOutput_p, err := common.GetStackResourcesOutput(cfn_client_p, stackName_p)
+	if err != nil {
+		return TfVars{}, nil, err
+	}
+	stacksOutput_p, err := common.GetStacksOutput(cfn_client_p, stackName_p)
+	if err != nil {
+		return TfVars{}, nil, err
+	}
 
 	tfvars := initTfVarsFromStackParams(*stacksOutput_p)
 	tfvars = mapTagsToTfvars(*stacksOutput_p, tfvars)
-	tfvars = mapResolverRuleDetailsToTfvars(*stackResourcesOutput_p, route53resolver_client_p, tfvars)
-	tfvars = mapDhcpOptionsToTfvars(*stackResourcesOutput_p, tfvars, ec2_client_p)
-	tfvars = mapTgwAttachmentDetailsToTfvars(*stackResourcesOutput_p, tfvars, ec2_client_p)
-	
-	writeTfvarsToFile(tfvars)
+	if tfvars, err = mapResolverRuleDetailsToTfvars(*stackResourcesOutput_p, route53resolver_client_p, tfvars); err != nil {
+		return TfVars{}, nil, err
+	}
+	if tfvars, err = mapDhcpOptionsToTfvars(*stackResourcesOutput_p, tfvars, ec2_client_p); err != nil {
+		return TfVars{}, nil, err
+	}
+	if tfvars, err = mapTgwAttachmentDetailsToTfvars(*stackResourcesOutput_p, tfvars, ec2_client_p); err != nil {
+		return TfVars{}, nil, err
+	}
+	if tfvars, err = mapSubnetsToTfvars(*stackResourcesOutput_p, tfvars, ec2_client_p); err != nil {
+		return TfVars{}, nil, err
+	}
+	return crossCheckLiveValues(*stackResourcesOutput_p, tfvars, ec2_client_p)
 }
 
 type TfVars struct {
@@ -37,7 +66,7 @@ type TfVars struct {
 	OrganizationId                string            `json:"OrganizationId"`
 	DomainNameServers             []string          `json:"DomainNameServers"`
 	DomainName                    string            `json:"DomainName"`
-	IpRange                       string            `json:"IpRange`
+	IpRange                       string            `json:"IpRange"`
 	MasterAccountId               string            `json:"MasterAccountId"`
 	SharedEnvironment             string            `json:"environment"`
 	TransitGatewayID              string            `json:"TransitGatewayID"`
@@ -52,20 +81,47 @@ type TfVars struct {
 	CrossVPCResolverRuleAssocName string            `json:"cross_vpc_resolver_rule_assoc_name"`
 	MskccTldResolverRuleAssocName string            `json:"mskcc_tld_resolver_rule_assoc_name"`
 	TgwAttachmentDnsSupport       string            `json:"tgw_attachment_dns_support"`
+	Subnets                       []SubnetVars      `json:"subnets"`
+}
+
+// SubnetVars is a subnet of the stack, in the order the vpc module's aws_subnet.this is indexed
+type SubnetVars struct {
+	Cidr string `json:"cidr"`
+	Az   string `json:"az"`
+	Name string `json:"name"`
 }
 
-func getSubnetDetails(ec2_client_p *ec2.Client, physicalResourceId string) (string, string) {
+// getSubnetDetails returns the subnet's cidr, availability zone and Name tag, empty when it has none
+func getSubnetDetails(ec2_client_p common.VpcDescriber, physicalResourceId string) (string, string, string, error) {
 	input := ec2.DescribeSubnetsInput{SubnetIds: []string{physicalResourceId}}
 	output, err := ec2_client_p.DescribeSubnets(context.TODO(), &input)
-	common.Check(err)
-	return *output.Subnets[0].CidrBlock, *output.Subnets[0].AvailabilityZone
+	if err != nil {
+		return "", "", "", fmt.Errorf("describing subnet %s: %w", physicalResourceId, err)
+	}
+	if len(output.Subnets) == 0 {
+		return "", "", "", fmt.Errorf("%w: subnet %s", common.ErrResourceNotFound, physicalResourceId)
+	}
+	name := ""
+	for _, tag := range output.Subnets[0].Tags {
+		if aws.ToString(tag.Key) == "Name" {
+			name = aws.ToString(tag.Value)
+		}
+	}
+	return *output.Subnets[0].CidrBlock, *output.Subnets[0].AvailabilityZone, name, nil
 }
 
-func getVpcRange(ec2_client_p *ec2.Client, physicalResourceId string) string {
-	input := ec2.DescribeVpcsInput{VpcIds: []string{physicalResourceId}}
-	output, err := ec2_client_p.DescribeVpcs(context.TODO(), &input)
-	common.Check(err)
-	return *output.Vpcs[0].CidrBlock
+// mapSubnetsToTfvars lists every subnet in the stack, however many it has, ordered by logical id like the
+// addresses they're imported to
+func mapSubnetsToTfvars(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, tfvars TfVars, ec2_client_p common.VpcDescriber) (TfVars, error) {
+	tfvars.Subnets = []SubnetVars{}
+	for _, resource := range common.StackResourcesByType(stackResourcesOutput_p, "AWS::EC2::Subnet") {
+		cidr, az, name, err := getSubnetDetails(ec2_client_p, *resource.PhysicalResourceId)
+		if err != nil {
+			return tfvars, err
+		}
+		tfvars.Subnets = append(tfvars.Subnets, SubnetVars{Cidr: cidr, Az: az, Name: name})
+	}
+	return tfvars, nil
 }
 
 func getPhysicalResourceIdByLogicalResourceId(stackResourcesOutput cloudformation.DescribeStackResourcesOutput, targetLogicalResourceId string) string {
@@ -81,28 +137,37 @@ func getPhysicalResourceIdByLogicalResourceId(stackResourcesOutput cloudformatio
 	return ""
 }
 
-func mapDhcpOptionsToTfvars(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, tfvars TfVars, ec2_client_p *ec2.Client) TfVars {
+func mapDhcpOptionsToTfvars(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, tfvars TfVars, ec2_client_p common.VpcDescriber) (TfVars, error) {
 	for _, resource := range stackResourcesOutput_p.StackResources {
 		physicalResourceId := *resource.PhysicalResourceId
 		logicalResourceId := *resource.LogicalResourceId
 
 		switch logicalResourceId {
 		case "VPC":
-			tfvars.DhcpOptions = common.GetDhcpOptionsIdFromVpc(ec2_client_p, physicalResourceId)
+			dhcpOptionsId, err := common.GetDhcpOptionsIdFromVpc(ec2_client_p, physicalResourceId)
+			if err != nil {
+				return tfvars, err
+			}
+			tfvars.DhcpOptions = dhcpOptionsId
 		}
 	}
-	return tfvars
+	return tfvars, nil
 }
 
-func mapTgwAttachmentDetailsToTfvars(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, tfvars TfVars, ec2_client_p *ec2.Client) TfVars {
+func mapTgwAttachmentDetailsToTfvars(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, tfvars TfVars, ec2_client_p common.VpcDescriber) (TfVars, error) {
 	tgwAttachmentId := getPhysicalResourceIdByLogicalResourceId(stackResourcesOutput_p, "TgwAttach")
 	filterName := "transit-gateway-attachment-id"
 	input := ec2.DescribeTransitGatewayVpcAttachmentsInput{Filters: []ec2_types.Filter{{Name: &filterName, Values: []string{tgwAttachmentId}}}}
 	tgwAttachmentsOutput, err := ec2_client_p.DescribeTransitGatewayVpcAttachments(context.TODO(), &input)
-	common.Check(err)
+	if err != nil {
+		return tfvars, fmt.Errorf("describing transit gateway attachment %s: %w", tgwAttachmentId, err)
+	}
+	if len(tgwAttachmentsOutput.TransitGatewayVpcAttachments) == 0 {
+		return tfvars, fmt.Errorf("%w: transit gateway attachment %s", common.ErrResourceNotFound, tgwAttachmentId)
+	}
 	dnsSupport := tgwAttachmentsOutput.TransitGatewayVpcAttachments[0].Options.DnsSupport
 	tfvars.TgwAttachmentDnsSupport = getStringFromDnsSupportEnum(dnsSupport)
-	return tfvars
+	return tfvars, nil
 }
 
 func getStringFromDnsSupportEnum(dnsSupportEnum ec2_types.DnsSupportValue) string {
@@ -133,41 +198,54 @@ func initTfVarsFromStackParams(stacksOutput cloudformation.DescribeStacksOutput)
 		}
 	}
 	return TfVars{
-		SubnetCidrBits: params["SubnetCidrBits"],
-		OrganizationId: params["OrganizationId"],
-		DomainNameServers: []string{params["DomainNameServers"]},
-		DomainName: params["DomainName"],
-		IpRange: params["IpRange"],
-		MasterAccountId: params["MasterAccountId"],
-		SharedEnvironment: params["SharedEnvironment"],
-		TransitGatewayID: params["TransitGatewayID"],
-		TgwRouteTableID: params["TgwRouteTableID"],
-		TgwMSKRouteTableID: params["TgwMSKRouteTableID"],
-		VpcShareOU: params["VpcShareOU"],
-		DhcpOptions: params["DhcpOptions"],
-		InternetResolverRuleId: params["InternetResolverRuleId"],
-		CrossVpcResolverRuleId: params["CrossVpcResolverRuleId"],
-		MskccTldResolverRuleId: params["MskccTldResolverRuleId"],
+		SubnetCidrBits:                params["SubnetCidrBits"],
+		OrganizationId:                params["OrganizationId"],
+		DomainNameServers:             splitListParameter(params["DomainNameServers"]),
+		DomainName:                    params["DomainName"],
+		IpRange:                       params["IpRange"],
+		MasterAccountId:               params["MasterAccountId"],
+		SharedEnvironment:             params["SharedEnvironment"],
+		TransitGatewayID:              params["TransitGatewayID"],
+		TgwRouteTableID:               params["TgwRouteTableID"],
+		TgwMSKRouteTableID:            params["TgwMSKRouteTableID"],
+		VpcShareOU:                    params["VpcShareOU"],
+		DhcpOptions:                   params["DhcpOptions"],
+		InternetResolverRuleId:        params["InternetResolverRuleId"],
+		CrossVpcResolverRuleId:        params["CrossVpcResolverRuleId"],
+		MskccTldResolverRuleId:        params["MskccTldResolverRuleId"],
 		InternetResolverRuleAssocName: params["InternetResolverRuleAssocName"],
 		CrossVPCResolverRuleAssocName: params["CrossVPCResolverRuleAssocName"],
 		MskccTldResolverRuleAssocName: params["MskccTldResolverRuleAssocName"],
-		TgwAttachmentDnsSupport: params["TgwAttachmentDnsSupport"],
+		TgwAttachmentDnsSupport:       params["TgwAttachmentDnsSupport"],
 	}
 }
 
-func mapResolverRuleDetailsToTfvars(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, client *route53resolver.Client, tfvars TfVars) TfVars {
-	vpcId := getPhysicalResourceIdByLogicalResourceId(stackResourcesOutput_p, "VPC")
-	internetAssoc := common.GetResolverRuleAssociation(client, vpcId, common.ResolverRuleInternet)
-	tldAssoc := common.GetResolverRuleAssociation(client, vpcId, common.ResolverRuleTldIac)
+// splitListParameter splits a CommaDelimitedList parameter into its values
+func splitListParameter(value string) []string {
+	values := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
 
-	if tldAssoc == nil {
-		tldAssoc = common.GetResolverRuleAssociation(client, vpcId, common.ResolverRuleTldLegacy)
+func mapResolverRuleDetailsToTfvars(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, client common.ResolverRuleLister, tfvars TfVars) (TfVars, error) {
+	vpcId := getPhysicalResourceIdByLogicalResourceId(stackResourcesOutput_p, "VPC")
+	internetAssoc, err := common.GetFirstResolverRuleAssociation(client, vpcId, common.ResolverRuleInternet)
+	if err != nil {
+		return tfvars, err
 	}
 
-	crossVpcAssoc := common.GetResolverRuleAssociation(client, vpcId, common.ResolverRuleCrossVpcIac)
+	tldAssoc, err := common.GetFirstResolverRuleAssociation(client, vpcId, common.ResolverRuleTldIac, common.ResolverRuleTldLegacy)
+	if err != nil {
+		return tfvars, err
+	}
 
-	if crossVpcAssoc == nil {
-		crossVpcAssoc = common.GetResolverRuleAssociation(client, vpcId, common.ResolverRuleCrossVpcLegacy)
+	crossVpcAssoc, err := common.GetFirstResolverRuleAssociation(client, vpcId, common.ResolverRuleCrossVpcIac, common.ResolverRuleCrossVpcLegacy)
+	if err != nil {
+		return tfvars, err
 	}
 
 	if internetAssoc != nil {
@@ -185,10 +263,10 @@ func mapResolverRuleDetailsToTfvars(stackResourcesOutput_p cloudformation.Descri
 		tfvars.MskccTldResolverRuleId = *tldAssoc.ResolverRuleId
 	}
 
-	return tfvars
+	return tfvars, nil
 }
 
-func writeTfvarsToFile(tfvars TfVars) {
+func writeTfvarsToFile(tfvars TfVars, workingDir string) error {
 	var out *bytes.Buffer = bytes.NewBuffer(make([]byte, 0, 4096))
 	var err error
 	var tfvarsJson []byte
@@ -199,21 +277,26 @@ func writeTfvarsToFile(tfvars TfVars) {
 	defer f.Close()
 
 	tfvarsJson, err = json.Marshal(tfvars)
-	common.Check(err)
+	if err != nil {
+		return err
+	}
 	json.Indent(out, tfvarsJson, "", "    ")
-	path, err = os.Getwd()
-	common.Check(err)
+	path, err = filepath.Abs(workingDir)
+	if err != nil {
+		return err
+	}
 	name = path + "/terraform.tfvars.json"
 
 	if _, err = os.Stat(name); err == nil {
-		err = errors.New("cli.go: writeTfvarsToFile(tfvars TfVars): tfvars file already exists: " + name)
-		log.Fatal(err)
+		return errors.New("cli.go: writeTfvarsToFile(tfvars TfVars): tfvars file already exists: " + name)
 	}
 
 	f, err = os.Create(name) // Note: This operation truncates an existing file
-	common.Check(err)
+	if err != nil {
+		return err
+	}
 	fmt.Fprintln(os.Stderr, "Writing JSON for generated tfvars to Path: "+f.Name())
 	w := bufio.NewWriter(f)
 	out.WriteTo(w)
-	w.Flush()
+	return w.Flush()
 }
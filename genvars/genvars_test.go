@@ -0,0 +1,99 @@
+package genvars
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"vpc-import-cli/common"
+	"vpc-import-cli/fake"
+)
+
+func loadTfVars(t *testing.T, path string) TfVars {
+	t.Helper()
+	tfvarsJson, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tfvars TfVars
+	if err = json.Unmarshal(tfvarsJson, &tfvars); err != nil {
+		t.Fatal(err)
+	}
+	return tfvars
+}
+
+func TestGenerateTfVars(t *testing.T) {
+	domainNameServersWarning := LiveValueWarning{TfVar: "DomainNameServers", ParameterValue: "AmazonProvidedDNS", LiveValue: "10.20.0.2"}
+
+	tests := []struct {
+		name         string
+		stackName    string
+		replacements []string
+		want         func(tfvars *TfVars)
+		wantWarnings []LiveValueWarning
+		wantErr      error
+	}{
+		{
+			name:         "recorded stack",
+			stackName:    "networking-dedicated-spoke-dev",
+			wantWarnings: []LiveValueWarning{domainNameServersWarning},
+		},
+		{
+			name:      "stale IpRange parameter takes the vpc cidr",
+			stackName: "networking-dedicated-spoke-dev",
+			replacements: []string{
+				`"ParameterKey": "IpRange",
+                "ParameterValue": "10.20.0.0/22"`,
+				`"ParameterKey": "IpRange",
+                "ParameterValue": "10.30.0.0/22"`,
+			},
+			wantWarnings: []LiveValueWarning{
+				{TfVar: "IpRange", ParameterValue: "10.30.0.0/22", LiveValue: "10.20.0.0/22"},
+				domainNameServersWarning,
+			},
+		},
+		{
+			name:      "subnet without a Name tag",
+			stackName: "networking-dedicated-spoke-dev",
+			replacements: []string{
+				`"Value": "networking-dedicated-spoke-dev-subnet-2"`,
+				`"Value": ""`,
+			},
+			want: func(tfvars *TfVars) {
+				tfvars.Subnets[1].Name = ""
+			},
+			wantWarnings: []LiveValueWarning{domainNameServersWarning},
+		},
+		{
+			name:      "stack not found",
+			stackName: "does-not-exist",
+			wantErr:   common.ErrStackNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.LoadFixture(t, tt.replacements...)
+			stackName := tt.stackName
+			tfvars, warnings, err := GenerateTfVars(client, client, client, &stackName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GenerateTfVars() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			want := loadTfVars(t, "testdata/networking-dedicated-spoke-dev.tfvars.json")
+			if tt.want != nil {
+				tt.want(&want)
+			}
+			if !reflect.DeepEqual(tfvars, want) {
+				t.Errorf("GenerateTfVars() tfvars = %+v, want %+v", tfvars, want)
+			}
+			if !reflect.DeepEqual(warnings, tt.wantWarnings) {
+				t.Errorf("GenerateTfVars() warnings = %+v, want %+v", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
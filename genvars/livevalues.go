@@ -0,0 +1,124 @@
+package genvars
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2_types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"vpc-import-cli/common"
+)
+
+// LiveValueWarning is a tfvars value whose stack parameter differs from the live resource, ex. after
+// the resource was changed outside cloudformation. The live value is the one written
+type LiveValueWarning struct {
+	TfVar          string
+	ParameterValue string
+	LiveValue      string
+}
+
+// crossCheckLiveValues replaces tfvars taken from stack parameters with the live values of the resources
+// they configured: IpRange with the vpc cidr, TransitGatewayID with the attachment's transit gateway,
+// TgwRouteTableID with the route table the attachment is associated with, TgwMSKRouteTableID with the other
+// route table it propagates to, and DomainName/DomainNameServers with the vpc's dhcp options
+func crossCheckLiveValues(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, tfvars TfVars, ec2_client_p common.VpcDescriber) (TfVars, []LiveValueWarning, error) {
+	warnings := []LiveValueWarning{}
+	check := func(tfvar string, parameterValue string, liveValue string) string {
+		if liveValue == "" || liveValue == parameterValue {
+			return parameterValue
+		}
+		warnings = append(warnings, LiveValueWarning{TfVar: tfvar, ParameterValue: parameterValue, LiveValue: liveValue})
+		return liveValue
+	}
+
+	vpcRange, err := common.GetVpcRange(ec2_client_p, getPhysicalResourceIdByLogicalResourceId(stackResourcesOutput_p, "VPC"))
+	if err != nil {
+		return tfvars, nil, err
+	}
+	tfvars.IpRange = check("IpRange", tfvars.IpRange, vpcRange)
+
+	tgwAttachmentId := getPhysicalResourceIdByLogicalResourceId(stackResourcesOutput_p, "TgwAttach")
+	transitGatewayId, err := getTgwAttachmentTransitGatewayId(ec2_client_p, tgwAttachmentId)
+	if err != nil {
+		return tfvars, nil, err
+	}
+	tfvars.TransitGatewayID = check("TransitGatewayID", tfvars.TransitGatewayID, transitGatewayId)
+
+	associatedRouteTableId, err := common.GetTgwAttachmentAssociatedRouteTableId(ec2_client_p, tgwAttachmentId)
+	if err != nil {
+		return tfvars, nil, err
+	}
+	tfvars.TgwRouteTableID = check("TgwRouteTableID", tfvars.TgwRouteTableID, associatedRouteTableId)
+	mskRouteTableId, err := common.GetTgwAttachmentMSKRouteTableId(ec2_client_p, tgwAttachmentId, tfvars.TgwRouteTableID)
+	if err != nil {
+		return tfvars, nil, err
+	}
+	tfvars.TgwMSKRouteTableID = check("TgwMSKRouteTableID", tfvars.TgwMSKRouteTableID, mskRouteTableId)
+
+	dhcpConfigurations, err := getDhcpConfigurations(ec2_client_p, tfvars.DhcpOptions)
+	if err != nil {
+		return tfvars, nil, err
+	}
+	if domainNames := dhcpConfigurations["domain-name"]; len(domainNames) > 0 {
+		tfvars.DomainName = check("DomainName", tfvars.DomainName, domainNames[0])
+	}
+	if domainNameServers := dhcpConfigurations["domain-name-servers"]; len(domainNameServers) > 0 && !reflect.DeepEqual(domainNameServers, tfvars.DomainNameServers) {
+		warnings = append(warnings, LiveValueWarning{
+			TfVar:          "DomainNameServers",
+			ParameterValue: strings.Join(tfvars.DomainNameServers, ","),
+			LiveValue:      strings.Join(domainNameServers, ","),
+		})
+		tfvars.DomainNameServers = domainNameServers
+	}
+	return tfvars, warnings, nil
+}
+
+func getTgwAttachmentTransitGatewayId(ec2_client_p common.VpcDescriber, tgwAttachmentId string) (string, error) {
+	filterName := "transit-gateway-attachment-id"
+	input := ec2.DescribeTransitGatewayVpcAttachmentsInput{Filters: []ec2_types.Filter{{Name: &filterName, Values: []string{tgwAttachmentId}}}}
+	output, err := ec2_client_p.DescribeTransitGatewayVpcAttachments(context.TODO(), &input)
+	if err != nil {
+		return "", fmt.Errorf("describing transit gateway attachment %s: %w", tgwAttachmentId, err)
+	}
+	if len(output.TransitGatewayVpcAttachments) == 0 {
+		return "", fmt.Errorf("%w: transit gateway attachment %s", common.ErrResourceNotFound, tgwAttachmentId)
+	}
+	return *output.TransitGatewayVpcAttachments[0].TransitGatewayId, nil
+}
+
+// getDhcpConfigurations returns the dhcp options set's values keyed by option, ex. domain-name-servers
+func getDhcpConfigurations(ec2_client_p common.VpcDescriber, dhcpOptionsId string) (map[string][]string, error) {
+	input := ec2.DescribeDhcpOptionsInput{DhcpOptionsIds: []string{dhcpOptionsId}}
+	output, err := ec2_client_p.DescribeDhcpOptions(context.TODO(), &input)
+	if err != nil {
+		return nil, fmt.Errorf("describing dhcp options %s: %w", dhcpOptionsId, err)
+	}
+	if len(output.DhcpOptions) == 0 {
+		return nil, fmt.Errorf("%w: dhcp options %s", common.ErrResourceNotFound, dhcpOptionsId)
+	}
+	configurations := map[string][]string{}
+	for _, configuration := range output.DhcpOptions[0].DhcpConfigurations {
+		for _, value := range configuration.Values {
+			configurations[*configuration.Key] = append(configurations[*configuration.Key], *value.Value)
+		}
+	}
+	return configurations, nil
+}
+
+// PrintLiveValueWarnings writes a warnings section listing the tfvars whose stack parameter was overridden
+// by the live value
+func PrintLiveValueWarnings(out io.Writer, warnings []LiveValueWarning) {
+	fmt.Fprintln(out, "Warnings: stack parameters differ from the live resources, the live values were written")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TFVAR\tSTACK PARAMETER\tLIVE VALUE\t")
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", warning.TfVar, warning.ParameterValue, warning.LiveValue)
+	}
+	w.Flush()
+}
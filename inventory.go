@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"gopkg.in/yaml.v3"
+)
+
+// inventory lists the accounts and regions to run a batch in, ex.
+//
+//	targets:
+//	  - role_arn: arn:aws:iam::111111111111:role/vpc-import
+//	    region: us-east-1
+//	    stacks: [networking-dedicated-spoke-dev, networking-dedicated-spoke-prod]
+//	  - role_arn: arn:aws:iam::222222222222:role/vpc-import
+//	    region: us-west-2
+//	    stack_pattern: ^networking-dedicated-spoke-
+type inventory struct {
+	Targets []inventoryTarget `yaml:"targets"`
+}
+
+type inventoryTarget struct {
+	RoleArn      string   `yaml:"role_arn"`
+	Region       string   `yaml:"region"`
+	Stacks       []string `yaml:"stacks"`
+	StackPattern string   `yaml:"stack_pattern"`
+}
+
+func loadInventory(path string) (inventory, error) {
+	inventoryYaml, err := os.ReadFile(path)
+	if err != nil {
+		return inventory{}, err
+	}
+	var inv inventory
+	if err = yaml.Unmarshal(inventoryYaml, &inv); err != nil {
+		return inventory{}, fmt.Errorf("parsing inventory %s: %w", path, err)
+	}
+	for i, target := range inv.Targets {
+		if len(target.Stacks) == 0 && target.StackPattern == "" {
+			return inventory{}, fmt.Errorf("inventory %s: target %d needs stacks or stack_pattern", path, i)
+		}
+		if target.RoleArn != "" && !arn.IsARN(target.RoleArn) {
+			return inventory{}, fmt.Errorf("inventory %s: target %d role_arn %q isn't an arn", path, i, target.RoleArn)
+		}
+	}
+	return inv, nil
+}
+
+// workDir is the target's directory under the batch work dir, named for the role's account and the region
+// so stacks with the same name in different accounts don't share state
+func (target inventoryTarget) workDir(parent string) string {
+	account := "default"
+	if parsed, err := arn.Parse(target.RoleArn); err == nil {
+		account = parsed.AccountID
+	}
+	region := target.Region
+	if region == "" {
+		region = "default"
+	}
+	return filepath.Join(parent, account+"-"+region)
+}
@@ -4,50 +4,361 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"os"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 
 	"vpc-import-cli/common"
+	"vpc-import-cli/fake"
 	"vpc-import-cli/genvars"
 	"vpc-import-cli/tf_import"
 )
 
+// subcommands, run as vpc-import-cli <command> --flags. Without one the cli runs --genvars/--import
+var commands = map[string]func(args []string){
+	"converge":     runConverge,
+	"discover":     runDiscover,
+	"exports":      runExports,
+	"genconfig":    runGenconfig,
+	"remap":        runRemap,
+	"retire-stack": runRetireStack,
+	"rollback":     runRollback,
+	"snapshot":     runSnapshot,
+	"stub":         runStub,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
 	// new() initializes the var and returns a pointer to it, so
 	// for ex. stackName_p is a pointer
 	stackName_p := new(string)
 	import_p := new(bool)
 	genvars_p := new(bool)
+	importBlocks_p := new(bool)
+	dryRun_p := new(bool)
+	resume_p := new(bool)
+	verify_p := new(bool)
+	mappingPath_p := new(string)
+	fromSnapshot_p := new(string)
+	stacksFile_p := new(string)
+	stackPattern_p := new(string)
+	concurrency_p := new(int)
+	workDir_p := new(string)
+	region_p := new(string)
+	roleArn_p := new(string)
+	inventory_p := new(string)
+	vpcId_p := new(string)
+	allowDrift_p := new(bool)
+	moduleAddress_p := new(string)
+	autoApprove_p := new(bool)
 	// the flag methods takes a pointer to the var which will hold the
 	// value from the command line
-	flag.StringVar(stackName_p, "stack-name", "", "The StackName of the networking-dedicated-spoke stack to import")
+	flag.StringVar(stackName_p, "stack-name", "", "The StackName or stack ARN of the networking-dedicated-spoke stack to import, an ARN also sets the region")
+	flag.StringVar(vpcId_p, "vpc-id", "", "ID of the VPC, set instead of --stack-name to import the stack that created it")
 	flag.BoolVar(import_p, "import", false, "Boolean flag, set to import stack with name passed to --stack-name")
+	flag.BoolVar(importBlocks_p, "import-blocks", false, "Boolean flag, set with --import to write an imports.tf of terraform import blocks and import with a single plan/apply (requires terraform 1.5+)")
+	flag.BoolVar(autoApprove_p, "auto-approve", false, "Boolean flag, set with --import-blocks to apply the printed plan without the confirmation prompt, required with --stacks-file, --stack-pattern or --inventory")
+	flag.BoolVar(dryRun_p, "dry-run", false, "Boolean flag, set with --import to print the resources that would be imported without touching terraform state")
+	flag.BoolVar(resume_p, "resume", false, "Boolean flag, set with --import to skip addresses already imported according to "+tf_import.ManifestFileName+" or already in terraform state")
+	flag.BoolVar(verify_p, "verify", false, "Boolean flag, set to run terraform plan against the generated tfvars after --import (or on its own) and report resources that would change, fails if the vpc, subnets or tgw attachment would be replaced")
+	flag.BoolVar(allowDrift_p, "allow-drift", false, "Boolean flag, set with --import to import even though cloudformation drift detection found drifted resources, the drift is still reported")
+	flag.StringVar(moduleAddress_p, "module-address", "", "Go template of the module address to import to instead of the mapping's module.vpc, ex. 'module.spoke[\"{{.StackName}}\"]'")
+	flag.StringVar(mappingPath_p, "mapping", "", "Path to a YAML or JSON mapping file of logical ids to terraform addresses, defaults to the embedded networking-dedicated-spoke mapping")
 	flag.BoolVar(genvars_p, "genvars", false, "Boolean flag, set to generate tfvars file for stack with name passed to --stack-name")
+	flag.StringVar(fromSnapshot_p, "from-snapshot", "", "Path to an archive written by the snapshot command, set to run --genvars or --import --dry-run from it instead of aws")
+	flag.StringVar(stacksFile_p, "stacks-file", "", "Path to a file of stack names, one per line, set to run --genvars/--import/--verify for each of them instead of --stack-name")
+	flag.StringVar(stackPattern_p, "stack-pattern", "", "Regular expression, set to run --genvars/--import/--verify for every stack whose name matches instead of --stack-name")
+	flag.IntVar(concurrency_p, "concurrency", 4, "Number of stacks processed at once with --stacks-file or --stack-pattern")
+	flag.StringVar(workDir_p, "work-dir", "stacks", "Directory holding a working directory per stack with --stacks-file or --stack-pattern")
+	flag.StringVar(region_p, "region", "", "AWS region of the stack, defaults to the region in the shared aws config. Also written to a providers_override.tf for terraform")
+	flag.StringVar(roleArn_p, "role-arn", "", "ARN of a role to assume to read the stack, ex. in the spoke's account. Also written to a providers_override.tf for terraform")
+	flag.StringVar(inventory_p, "inventory", "", "Path to a YAML inventory of role ARNs, regions and stacks, set to run a batch across accounts and regions")
 	flag.Parse()
+	batch := *stacksFile_p != "" || *stackPattern_p != "" || *inventory_p != ""
 	// *stackName_p is the value pointed to by stackName_p
-	if *stackName_p == "" {
-		log.Fatal(errors.New("value for '--stack-name' flag is required"))
+	if *stackName_p == "" && *vpcId_p == "" && !batch && (*genvars_p || *import_p) {
+		usageError(flag.CommandLine, "value for '--stack-name' or '--vpc-id' flag is required")
+	}
+	if *vpcId_p != "" && (*stackName_p != "" || batch) {
+		usageError(flag.CommandLine, "--vpc-id can't be used with --stack-name, --stacks-file, --stack-pattern or --inventory")
+	}
+	parseStackArnFlag(flag.CommandLine, stackName_p, region_p, *roleArn_p, *fromSnapshot_p)
+	if batch && (*stackName_p != "" || (*stacksFile_p != "" && *stackPattern_p != "")) {
+		usageError(flag.CommandLine, "only one of --stack-name, --stacks-file or --stack-pattern can be used")
+	}
+	if *inventory_p != "" && (*stacksFile_p != "" || *stackPattern_p != "" || *region_p != "" || *roleArn_p != "") {
+		usageError(flag.CommandLine, "--inventory sets the stacks, regions and roles, it can't be used with --stacks-file, --stack-pattern, --region or --role-arn")
+	}
+	if batch && *dryRun_p {
+		usageError(flag.CommandLine, "--dry-run can't be used with --stacks-file or --stack-pattern")
+	}
+	if *concurrency_p < 1 {
+		usageError(flag.CommandLine, "--concurrency must be at least 1")
+	}
+	if !*genvars_p && !*import_p && !*verify_p {
+		usageError(flag.CommandLine, "one of --genvars, --import or --verify is required")
+	}
+	if *importBlocks_p && !*import_p {
+		usageError(flag.CommandLine, "--import-blocks can only be used with --import")
+	}
+	if *autoApprove_p && !*importBlocks_p {
+		usageError(flag.CommandLine, "--auto-approve can only be used with --import-blocks")
+	}
+	if batch && *importBlocks_p && !*autoApprove_p {
+		usageError(flag.CommandLine, "--import-blocks needs --auto-approve with --stacks-file, --stack-pattern or --inventory, the stacks can't prompt")
+	}
+	if *dryRun_p && !*import_p {
+		usageError(flag.CommandLine, "--dry-run can only be used with --import")
+	}
+	if *resume_p && (!*import_p || *dryRun_p) {
+		usageError(flag.CommandLine, "--resume can only be used with --import, and not with --dry-run")
+	}
+	if *allowDrift_p && (!*import_p || *dryRun_p) {
+		usageError(flag.CommandLine, "--allow-drift can only be used with --import, and not with --dry-run")
 	}
-	if !*genvars_p && !*import_p {
-		log.Fatal(errors.New("either --genvars or --import is required"))
+	if *verify_p && (*dryRun_p || *fromSnapshot_p != "") {
+		usageError(flag.CommandLine, "--verify can't be used with --dry-run or --from-snapshot")
+	}
+	if *fromSnapshot_p != "" && *import_p && !*dryRun_p {
+		usageError(flag.CommandLine, "--from-snapshot can only be used with --import when --dry-run is set")
+	}
+	// a snapshot records a single stack, listing the stacks to match a pattern needs aws
+	if *fromSnapshot_p != "" && (*stackPattern_p != "" || *inventory_p != "") {
+		usageError(flag.CommandLine, "--from-snapshot can't be used with --stack-pattern or --inventory, a snapshot holds a single stack")
+	}
+
+	if batch {
+		mapping, err := tf_import.LoadMapping(*mappingPath_p)
+		exitOnError(err)
+		if *moduleAddress_p != "" {
+			mapping.ModuleAddress = *moduleAddress_p
+		}
+		var stacks []batchStack
+		if *inventory_p != "" {
+			inv, err := loadInventory(*inventory_p)
+			exitOnError(err)
+			for _, target := range inv.Targets {
+				targetStacks, err := resolveBatchStacks(target, "", *fromSnapshot_p, target.workDir(*workDir_p))
+				exitOnError(err)
+				stacks = append(stacks, targetStacks...)
+			}
+		} else {
+			target := inventoryTarget{RoleArn: *roleArn_p, Region: *region_p, StackPattern: *stackPattern_p}
+			stacks, err = resolveBatchStacks(target, *stacksFile_p, *fromSnapshot_p, *workDir_p)
+			exitOnError(err)
+		}
+		results, err := runBatch(stacks, batchOptions{
+			genvars:      *genvars_p,
+			importStack:  *import_p,
+			importBlocks: *importBlocks_p,
+			resume:       *resume_p,
+			allowDrift:   *allowDrift_p,
+			verify:       *verify_p,
+			mapping:      mapping,
+			concurrency:  *concurrency_p,
+		})
+		printBatchSummary(os.Stderr, results)
+		exitOnError(err)
+		return
+	}
+
+	cfn_client_p, ec2_client_p, route53resolver_client_p, err := newClients(*fromSnapshot_p, *region_p, *roleArn_p)
+	exitOnError(err)
+	if *vpcId_p != "" {
+		*stackName_p, err = common.GetStackNameFromVpc(ec2_client_p, *vpcId_p)
+		exitOnError(err)
+		log.Printf("Using stack %s, which created VPC %s", *stackName_p, *vpcId_p)
+	}
+	// only terraform reads the override, a --genvars only run leaves it alone
+	if (*import_p && !*dryRun_p) || *verify_p {
+		exitOnError(writeProviderOverride(".", *region_p, *roleArn_p))
+	}
+
+	mapping, err := tf_import.LoadMapping(*mappingPath_p)
+	exitOnError(err)
+	if *moduleAddress_p != "" {
+		mapping.ModuleAddress = *moduleAddress_p
+	}
+
+	// before genvars too, so tfvars aren't written for a stack that won't be imported
+	if *import_p && !*dryRun_p {
+		drifts, err := tf_import.Preflight(cfn_client_p, mapping, stackName_p, *allowDrift_p)
+		if len(drifts) > 0 {
+			tf_import.PrintDrift(os.Stderr, drifts)
+		}
+		exitOnError(err)
+	}
+
+	if *genvars_p {
+		exitOnError(genvars.Genvars(cfn_client_p, ec2_client_p, route53resolver_client_p, stackName_p, "."))
+	}
+
+	if *import_p && *dryRun_p {
+		exitOnError(tf_import.TerraformImportDryRun(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p))
+	} else if *import_p {
+		var results []tf_import.ImportResult
+		if *importBlocks_p {
+			confirm := confirmStepOnStdin
+			if *autoApprove_p {
+				confirm = func(step string) bool { return true }
+			}
+			results, err = tf_import.TerraformImportBlocks(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p, ".", *resume_p, confirm)
+		} else {
+			results, err = tf_import.TerraformImport(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p, ".", *resume_p)
+		}
+		if len(results) > 0 {
+			tf_import.PrintImportSummary(os.Stderr, results)
+		}
+		exitOnError(err)
+	}
+
+	if *verify_p {
+		changes, err := tf_import.TerraformVerify(".")
+		if changes != nil {
+			tf_import.PrintPlannedChanges(os.Stdout, changes)
+		}
+		exitOnError(err)
+	}
+}
+
+// exit codes, so scripts driving the cli can tell failures apart
+const (
+	exitError               = 1
+	exitUsage               = 2
+	exitStackNotFound       = 3
+	exitResolverRuleMissing = 4
+	exitImportFailed        = 5
+	exitCriticalReplacement = 6
+	exitStackUnstable       = 7
+	exitStackDrifted        = 8
+)
+
+// parseStackArnFlag replaces a stack ARN passed to --stack-name with the stack's name and sets the region from it.
+// The ARN's account has to be --role-arn's, or without a role the account of the shared aws config's credentials
+func parseStackArnFlag(flags *flag.FlagSet, stackName_p *string, region_p *string, roleArn string, snapshotPath string) {
+	stackArn, ok := common.ParseStackArn(*stackName_p)
+	if !ok {
+		return
+	}
+	if *region_p != "" && *region_p != stackArn.Region {
+		usageError(flags, "--region "+*region_p+" doesn't match the stack ARN's region "+stackArn.Region)
+	}
+	if roleArn != "" {
+		if parsed, err := arn.Parse(roleArn); err == nil && parsed.AccountID != stackArn.AccountId {
+			usageError(flags, "--role-arn is in account "+parsed.AccountID+" but the stack ARN is in account "+stackArn.AccountId)
+		}
+	} else if snapshotPath == "" {
+		accountId, err := callerAccountId(stackArn.Region)
+		exitOnError(err)
+		if accountId != stackArn.AccountId {
+			exitOnError(fmt.Errorf("the aws credentials are for account %s but the stack ARN is in account %s, pass --role-arn to assume a role in it", accountId, stackArn.AccountId))
+		}
+	}
+	log.Printf("Using stack %s in account %s, region %s", stackArn.StackName, stackArn.AccountId, stackArn.Region)
+	*stackName_p = stackArn.StackName
+	*region_p = stackArn.Region
+}
+
+// callerAccountId returns the account of the credentials in the shared aws config
+func callerAccountId(region string) (string, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+	if err != nil {
+		return "", err
+	}
+	output, err := sts.NewFromConfig(cfg).GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("getting the caller identity to check the stack ARN's account: %w", err)
+	}
+	return aws.ToString(output.Account), nil
+}
+
+// newClients returns the aws clients, or a fake replaying the snapshot archive when a path is passed.
+// region and roleArn are optional, when set the clients use that region and assume the role
+func newClients(snapshotPath string, region string, roleArn string) (common.StackDescriber, common.VpcDescriber, common.ResolverRuleLister, error) {
+	if snapshotPath != "" {
+		client, err := fake.Load(snapshotPath)
+		return client, client, client, err
+	}
+
+	cfg, err := loadConfig(region)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if roleArn != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleArn))
 	}
-	// Load the Shared AWS Configuration (~/.aws/config)
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	common.Check(err)
 
 	// these methods also return points to the clients
 	cfn_client_p := cloudformation.NewFromConfig(cfg)
 	ec2_client_p := ec2.NewFromConfig(cfg)
 	route53resolver_client_p := route53resolver.NewFromConfig(cfg)
+	return cfn_client_p, ec2_client_p, route53resolver_client_p, nil
+}
 
-	if *genvars_p {
-		genvars.Genvars(cfn_client_p, ec2_client_p, route53resolver_client_p, stackName_p)
+// loadConfig loads the Shared AWS Configuration (~/.aws/config), with the region overridden when one is passed
+func loadConfig(region string) (aws.Config, error) {
+	optFns := []func(*config.LoadOptions) error{}
+	if region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	}
+	return config.LoadDefaultConfig(context.TODO(), optFns...)
+}
+
+// writeProviderOverride writes the provider override with the region the aws clients use, the passed region or
+// else the one in the shared aws config, so terraform never falls back to the region in providers.tf
+func writeProviderOverride(workingDir string, region string, roleArn string) error {
+	cfg, err := loadConfig(region)
+	if err != nil {
+		return err
 	}
-	if *import_p {
-		tf_import.TerraformImport(cfn_client_p, ec2_client_p, route53resolver_client_p, stackName_p)
+	if cfg.Region == "" {
+		return errors.New("no aws region is configured, pass --region or set one in the shared aws config so terraform imports from the stack's region")
+	}
+	return tf_import.WriteProviderOverride(workingDir, cfg.Region, roleArn)
+}
+
+func usageError(flags *flag.FlagSet, message string) {
+	log.Print(errors.New(message))
+	flags.Usage()
+	os.Exit(exitUsage)
+}
+
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	log.Print(err)
+
+	var importFailed *common.ErrImportFailed
+	switch {
+	case errors.As(err, &importFailed):
+		os.Exit(exitImportFailed)
+	case errors.Is(err, common.ErrStackNotFound):
+		os.Exit(exitStackNotFound)
+	case errors.Is(err, common.ErrResolverRuleMissing):
+		os.Exit(exitResolverRuleMissing)
+	case errors.Is(err, common.ErrCriticalReplacement):
+		os.Exit(exitCriticalReplacement)
+	case errors.Is(err, common.ErrStackUnstable):
+		os.Exit(exitStackUnstable)
+	case errors.Is(err, common.ErrStackDrifted):
+		os.Exit(exitStackDrifted)
+	default:
+		os.Exit(exitError)
 	}
 }
@@ -1,3 +1,4 @@
 module "vpc" {
-    source = "./modules/vpc"
+    source  = "./modules/vpc"
+    subnets = var.subnets
 }
\ No newline at end of file
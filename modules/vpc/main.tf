@@ -1,12 +1,12 @@
 resource "aws_vpc" "main" {}
 resource "aws_route_table" "main" {}
 resource "aws_security_group" "base" {}
-resource "aws_subnet" "subnet_1" {}
-resource "aws_subnet" "subnet_2" {}
-resource "aws_subnet" "subnet_3" {}
-resource "aws_route_table_association" "rt_association_1" {}
-resource "aws_route_table_association" "rt_association_2" {}
-resource "aws_route_table_association" "rt_association_3" {}
+resource "aws_subnet" "this" {
+  count = length(var.subnets)
+}
+resource "aws_route_table_association" "this" {
+  count = length(var.subnets)
+}
 resource "aws_vpc_endpoint" "ec2" {}
 resource "aws_vpc_endpoint" "ec2messages" {}
 resource "aws_vpc_endpoint" "s3" {}
@@ -16,7 +16,9 @@ resource "aws_vpc_dhcp_options" "main" {}
 resource "aws_vpc_dhcp_options_association" "main" {}
 resource "aws_security_group_rule" "base_ingress_v4" {}
 resource "aws_security_group_rule" "base_egress" {}
-resource "aws_ec2_transit_gateway_vpc_attachment" "main" {}
+resource "aws_ec2_transit_gateway_vpc_attachment" "main" {
+  for_each = toset(["0"])
+}
 resource "aws_route53_resolver_rule_association" "mskcc_tld" {}
 resource "aws_route53_resolver_rule_association" "cross_vpc" {}
 resource "aws_default_network_acl" "main" {}
@@ -0,0 +1,29 @@
+moved {
+  from = aws_route_table_association.rt_association_1
+  to   = aws_route_table_association.this[0]
+}
+
+moved {
+  from = aws_route_table_association.rt_association_2
+  to   = aws_route_table_association.this[1]
+}
+
+moved {
+  from = aws_route_table_association.rt_association_3
+  to   = aws_route_table_association.this[2]
+}
+
+moved {
+  from = aws_subnet.subnet_1
+  to   = aws_subnet.this[0]
+}
+
+moved {
+  from = aws_subnet.subnet_2
+  to   = aws_subnet.this[1]
+}
+
+moved {
+  from = aws_subnet.subnet_3
+  to   = aws_subnet.this[2]
+}
@@ -0,0 +1,7 @@
+variable "subnets" {
+  type = list(object({
+    cidr = string
+    az   = string
+    name = string
+  }))
+}
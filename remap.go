@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+
+	"vpc-import-cli/common"
+	"vpc-import-cli/tf_import"
+)
+
+// runRemap migrates imported addresses from one mapping's layout to another's, with moved blocks or state mv
+func runRemap(args []string) {
+	flags := flag.NewFlagSet("remap", flag.ExitOnError)
+	oldMappingPath_p := flags.String("old-mapping", "", "Path to the YAML or JSON mapping the stacks were imported with")
+	newMappingPath_p := flags.String("new-mapping", "", "Path to the YAML or JSON mapping of the new module layout, defaults to the embedded networking-dedicated-spoke mapping")
+	out_p := flags.String("out", "", "Path to write the moved blocks to, ex. modules/vpc/moved.tf, defaults to stdout")
+	stateMv_p := flags.Bool("state-mv", false, "Boolean flag, set to run terraform state mv in each working directory passed as an argument (default .) instead of writing moved blocks")
+	dryRun_p := flags.Bool("dry-run", false, "Boolean flag, set with --state-mv to print the moves without changing state")
+	stackName_p := flags.String("stack-name", "", "The StackName or stack ARN of a stack built from the template, needed to pair a resource_type entry with the other mapping's logical ids")
+	region_p := flags.String("region", "", "AWS region of the stack, defaults to the region in the shared aws config")
+	roleArn_p := flags.String("role-arn", "", "ARN of a role to assume to read the stack")
+	fromSnapshot_p := flags.String("from-snapshot", "", "Path to an archive written by the snapshot command, set to read the stack from it instead of aws")
+	flags.Parse(args)
+
+	if *oldMappingPath_p == "" {
+		usageError(flags, "value for '--old-mapping' flag is required")
+	}
+	if *stateMv_p && *out_p != "" {
+		usageError(flags, "--out writes moved blocks, it can't be used with --state-mv")
+	}
+	if *stackName_p == "" && (*region_p != "" || *roleArn_p != "" || *fromSnapshot_p != "") {
+		usageError(flags, "--region, --role-arn and --from-snapshot are only used with --stack-name")
+	}
+	if *dryRun_p && !*stateMv_p {
+		usageError(flags, "--dry-run can only be used with --state-mv")
+	}
+	if !*stateMv_p && flags.NArg() > 0 {
+		usageError(flags, "working directories are only used with --state-mv")
+	}
+
+	parseStackArnFlag(flags, stackName_p, region_p, *roleArn_p, *fromSnapshot_p)
+
+	oldMapping, err := tf_import.LoadMapping(*oldMappingPath_p)
+	exitOnError(err)
+	newMapping, err := tf_import.LoadMapping(*newMappingPath_p)
+	exitOnError(err)
+	var ec2_client_p common.VpcDescriber
+	var stackResourcesOutput_p *cloudformation.DescribeStackResourcesOutput
+	if *stackName_p != "" {
+		var cfn_client_p common.StackDescriber
+		cfn_client_p, ec2_client_p, _, err = newClients(*fromSnapshot_p, *region_p, *roleArn_p)
+		exitOnError(err)
+		stackResourcesOutput_p, err = common.GetStackResourcesOutput(cfn_client_p, stackName_p)
+		exitOnError(err)
+	}
+	moves, err := tf_import.RemapAddresses(oldMapping, newMapping, ec2_client_p, stackResourcesOutput_p)
+	exitOnError(err)
+	if len(moves) == 0 {
+		log.Println("No addresses moved between the mappings")
+		return
+	}
+
+	if *stateMv_p {
+		workingDirs := flags.Args()
+		if len(workingDirs) == 0 {
+			workingDirs = []string{"."}
+		}
+		exitOnError(tf_import.TerraformStateMv(workingDirs, moves, *dryRun_p))
+		return
+	}
+	out := os.Stdout
+	if *out_p != "" {
+		out, err = os.Create(*out_p) // Note: This operation truncates an existing file
+		exitOnError(err)
+		defer out.Close()
+		log.Println("Writing moved blocks to Path: " + out.Name())
+	}
+	exitOnError(tf_import.WriteMovedBlocks(out, moves))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"vpc-import-cli/common"
+	"vpc-import-cli/tf_import"
+)
+
+// runRetireStack deletes the cloudformation stack once terraform owns its resources, retaining them
+func runRetireStack(args []string) {
+	flags := flag.NewFlagSet("retire-stack", flag.ExitOnError)
+	stackName_p := flags.String("stack-name", "", "The StackName or stack ARN of the imported networking-dedicated-spoke stack to retire")
+	region_p := flags.String("region", "", "AWS region of the stack, defaults to the region in the shared aws config")
+	roleArn_p := flags.String("role-arn", "", "ARN of a role to assume to update and delete the stack")
+	dryRun_p := flags.Bool("dry-run", false, "Boolean flag, set to print the steps without changing the stack")
+	mappingPath_p := flags.String("mapping", "", "Path to the YAML or JSON mapping the stack was imported with, defaults to the embedded networking-dedicated-spoke mapping")
+	deleteUnmapped_p := flags.Bool("delete-unmapped", false, "Boolean flag, set to delete the resources the mapping doesn't import with the stack")
+	yes_p := flags.Bool("yes", false, "Boolean flag, set to skip the confirmation prompt before each step")
+	flags.Parse(args)
+
+	if *stackName_p == "" {
+		usageError(flags, "value for '--stack-name' flag is required")
+	}
+	parseStackArnFlag(flags, stackName_p, region_p, *roleArn_p, "")
+
+	mapping, err := tf_import.LoadMapping(*mappingPath_p)
+	exitOnError(err)
+	cfn_client_p, _, _, err := newClients("", *region_p, *roleArn_p)
+	exitOnError(err)
+
+	confirm := confirmStepOnStdin
+	if *yes_p {
+		confirm = func(step string) bool { return true }
+	}
+	exitOnError(tf_import.RetireStack(cfn_client_p.(common.StackRetirer), mapping, stackName_p, ".", *deleteUnmapped_p, *dryRun_p, confirm))
+}
+
+// confirmStepOnStdin prints the step and waits for the operator to type yes
+func confirmStepOnStdin(step string) bool {
+	fmt.Fprintf(os.Stderr, "%s? Only 'yes' will be accepted: ", step)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"vpc-import-cli/tf_import"
+)
+
+// runRollback removes everything an --import run put in terraform state for a stack, ex. after importing
+// the wrong stack or with a broken mapping
+func runRollback(args []string) {
+	flags := flag.NewFlagSet("rollback", flag.ExitOnError)
+	stackName_p := flags.String("stack-name", "", "The StackName or stack ARN of the networking-dedicated-spoke stack to roll back")
+	mappingPath_p := flags.String("mapping", "", "Path to a YAML or JSON mapping file, used when "+tf_import.ManifestFileName+" has no entries for the stack")
+	moduleAddress_p := flags.String("module-address", "", "Go template of the module address the stack was imported to with --module-address, used with the mapping")
+	region_p := flags.String("region", "", "AWS region of the stack, used with the mapping when the manifest has no entries for the stack")
+	roleArn_p := flags.String("role-arn", "", "ARN of a role to assume to read the stack, used with the mapping when the manifest has no entries for the stack")
+	dryRun_p := flags.Bool("dry-run", false, "Boolean flag, set to print the addresses that would be removed from terraform state without removing them")
+	yes_p := flags.Bool("yes", false, "Boolean flag, set to skip the confirmation prompt")
+	flags.Parse(args)
+
+	if *stackName_p == "" {
+		usageError(flags, "value for '--stack-name' flag is required")
+	}
+	parseStackArnFlag(flags, stackName_p, region_p, *roleArn_p, "")
+
+	mapping, err := tf_import.LoadMapping(*mappingPath_p)
+	exitOnError(err)
+	if *moduleAddress_p != "" {
+		mapping.ModuleAddress = *moduleAddress_p
+	}
+	cfn_client_p, ec2_client_p, route53resolver_client_p, err := newClients("", *region_p, *roleArn_p)
+	exitOnError(err)
+
+	confirm := confirmOnStdin
+	if *yes_p {
+		confirm = func(addresses []string) bool { return true }
+	}
+	exitOnError(tf_import.TerraformRollback(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p, ".", *dryRun_p, confirm))
+}
+
+// confirmOnStdin lists the addresses and waits for the operator to type yes
+func confirmOnStdin(addresses []string) bool {
+	for _, address := range addresses {
+		fmt.Fprintln(os.Stderr, address)
+	}
+	fmt.Fprintf(os.Stderr, "\nRemove these %d addresses from terraform state? Only 'yes' will be accepted: ", len(addresses))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
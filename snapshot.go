@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"vpc-import-cli/fake"
+	"vpc-import-cli/genvars"
+	"vpc-import-cli/tf_import"
+)
+
+// runSnapshot captures every aws response --genvars, --import --dry-run and the exports command need for a stack into a single
+// JSON archive, which --from-snapshot replays without aws credentials
+func runSnapshot(args []string) {
+	flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	stackName_p := flags.String("stack-name", "", "The StackName or stack ARN of the networking-dedicated-spoke stack to snapshot")
+	out_p := flags.String("out", "", "Path to write the snapshot archive to, defaults to <stack-name>.snapshot.json")
+	region_p := flags.String("region", "", "AWS region of the stack, defaults to the region in the shared aws config")
+	roleArn_p := flags.String("role-arn", "", "ARN of a role to assume to read the stack")
+	mappingPath_p := flags.String("mapping", "", "Path to a YAML or JSON mapping file, the snapshot covers the aws lookups its import ids need")
+	flags.Parse(args)
+
+	if *stackName_p == "" {
+		usageError(flags, "value for '--stack-name' flag is required")
+	}
+	parseStackArnFlag(flags, stackName_p, region_p, *roleArn_p, "")
+	if *out_p == "" {
+		*out_p = *stackName_p + ".snapshot.json"
+	}
+
+	mapping, err := tf_import.LoadMapping(*mappingPath_p)
+	exitOnError(err)
+
+	cfn_client_p, ec2_client_p, route53resolver_client_p, err := newClients("", *region_p, *roleArn_p)
+	exitOnError(err)
+	recorder := fake.NewRecorder(cfn_client_p, ec2_client_p, route53resolver_client_p)
+
+	_, _, err = genvars.GenerateTfVars(recorder, recorder, recorder, stackName_p)
+	exitOnError(err)
+	_, _, err = tf_import.LoadImportTargets(recorder, recorder, recorder, mapping, stackName_p)
+	exitOnError(err)
+	_, err = tf_import.ListStackExports(recorder, recorder, recorder, mapping, stackName_p)
+	exitOnError(err)
+
+	log.Println("Writing snapshot to Path: " + *out_p)
+	exitOnError(recorder.Save(*out_p))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"vpc-import-cli/tf_import"
+)
+
+// runStub checks the module has a resource block for every address in the mapping, and can write the missing ones
+func runStub(args []string) {
+	flags := flag.NewFlagSet("stub", flag.ExitOnError)
+	mappingPath_p := flags.String("mapping", "", "Path to a YAML or JSON mapping file to check, defaults to the embedded networking-dedicated-spoke mapping")
+	moduleDir_p := flags.String("module-dir", "modules/vpc", "Directory of the terraform module the mapping imports to")
+	write_p := flags.Bool("write", false, "Boolean flag, set to append an empty resource block to main.tf in --module-dir for each missing address")
+	flags.Parse(args)
+
+	mapping, err := tf_import.LoadMapping(*mappingPath_p)
+	exitOnError(err)
+	stubs, err := tf_import.CheckModuleStubs(mapping, *moduleDir_p)
+	exitOnError(err)
+	tf_import.PrintStubReport(os.Stdout, *moduleDir_p, stubs)
+
+	unresolved := 0
+	for _, stub := range stubs {
+		if stub.Status == tf_import.StubUnkeyed || (stub.Status == tf_import.StubMissing && !*write_p) {
+			unresolved++
+		}
+	}
+	if *write_p {
+		exitOnError(tf_import.WriteMissingStubs(*moduleDir_p, stubs))
+	}
+	if unresolved > 0 {
+		exitOnError(fmt.Errorf("%s doesn't match the mapping for %d addresses, import would fail for them", *moduleDir_p, unresolved))
+	}
+}
@@ -0,0 +1,274 @@
+package tf_import
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// convergeRules maps a module resource attribute to the tfvars key that feeds it, keyed by
+// "<type>.<attribute>" or, where resources of the same type take different vars, "<type>.<name>.<attribute>"
+var convergeRules = map[string]string{
+	"aws_vpc.cidr_block":                                                                  "IpRange",
+	"aws_vpc_dhcp_options.domain_name":                                                    "DomainName",
+	"aws_vpc_dhcp_options.domain_name_servers":                                            "DomainNameServers",
+	"aws_vpc_dhcp_options_association.dhcp_options_id":                                    "dhcp_options",
+	"aws_route.transit_gateway_id":                                                        "TransitGatewayID",
+	"aws_ec2_transit_gateway_vpc_attachment.transit_gateway_id":                           "TransitGatewayID",
+	"aws_ec2_transit_gateway_vpc_attachment.dns_support":                                  "tgw_attachment_dns_support",
+	"aws_ec2_transit_gateway_route.transit_gateway_route_table_id":                        "TgwRouteTableID",
+	"aws_ec2_transit_gateway_route_table_association.transit_gateway_route_table_id":      "TgwRouteTableID",
+	"aws_ec2_transit_gateway_route_table_propagation.main.transit_gateway_route_table_id": "TgwRouteTableID",
+	"aws_ec2_transit_gateway_route_table_propagation.msk.transit_gateway_route_table_id":  "TgwMSKRouteTableID",
+	"aws_route53_resolver_rule_association.internet.resolver_rule_id":                     "internet_resolver_rule_id",
+	"aws_route53_resolver_rule_association.internet.name":                                 "internet_resolver_rule_assoc_name",
+	"aws_route53_resolver_rule_association.cross_vpc.resolver_rule_id":                    "cross_vpc_resolver_rule_id",
+	"aws_route53_resolver_rule_association.cross_vpc.name":                                "cross_vpc_resolver_rule_assoc_name",
+	"aws_route53_resolver_rule_association.mskcc_tld.resolver_rule_id":                    "mskcc_tld_resolver_rule_id",
+	"aws_route53_resolver_rule_association.mskcc_tld.name":                                "mskcc_tld_resolver_rule_assoc_name",
+}
+
+// convergeListRules maps an attribute of a counted module resource to a field of the object at the same index
+// of a tfvars list, keyed by "<type>.<name>.<attribute>" where the attribute can be a map key, ex. tags.Name
+var convergeListRules = map[string]convergeListRule{
+	"aws_subnet.this.cidr_block":        {Key: "subnets", Field: "cidr"},
+	"aws_subnet.this.availability_zone": {Key: "subnets", Field: "az"},
+	"aws_subnet.this.tags.Name":         {Key: "subnets", Field: "name"},
+}
+
+type convergeListRule struct {
+	Key   string
+	Field string
+}
+
+// TfvarsChange is a tfvars value converge set from the imported state of Source, an <address>.<attribute>.
+// Key is <key>[<index>].<field> for a field of an object in a tfvars list
+type TfvarsChange struct {
+	Key    string
+	Old    interface{}
+	New    interface{}
+	Source string
+
+	// set for a field of an object in a tfvars list
+	listRule *convergeListRule
+	index    int
+}
+
+// ConvergeResult is what converge changed, and the plan diffs it couldn't resolve
+type ConvergeResult struct {
+	Rounds    int
+	Changes   []TfvarsChange
+	Conflicts []string
+	Remaining []PlannedChange
+}
+
+// TerraformConverge plans against the generated tfvars, sets each tfvars value that feeds a differing attribute
+// to the attribute's value in the imported state, and plans again - until the plan is clean, a round changes
+// nothing or maxRounds is reached. With dryRun set only the first round's changes are worked out, the tfvars
+// file isn't written
+func TerraformConverge(workingDir string, maxRounds int, dryRun bool) (ConvergeResult, error) {
+	result := ConvergeResult{}
+	tfvars, err := readTfvars(workingDir)
+	if err != nil {
+		return result, err
+	}
+
+	tf, err := terraformInit(workingDir, importBlocksTerraformVersion)
+	if err != nil {
+		return result, err
+	}
+
+	for result.Rounds < maxRounds {
+		result.Rounds++
+		log.Printf("Converge round %d: running terraform plan...", result.Rounds)
+		plan, err := terraformPlan(tf)
+		if err != nil {
+			return result, err
+		}
+		result.Remaining = plannedChanges(plan)
+		if len(result.Remaining) == 0 {
+			return result, nil
+		}
+
+		changes, conflicts := proposeTfvarsChanges(plan, tfvars)
+		result.Conflicts = conflicts
+		if len(changes) == 0 {
+			return result, nil
+		}
+		result.Changes = append(result.Changes, changes...)
+		if dryRun {
+			return result, nil
+		}
+
+		for _, change := range changes {
+			log.Printf("Setting %s from %s", change.Key, change.Source)
+			setTfvar(tfvars, change)
+		}
+		if err = writeTfvars(workingDir, tfvars); err != nil {
+			return result, err
+		}
+	}
+	log.Printf("Stopping after %d rounds", maxRounds)
+	return result, nil
+}
+
+// proposeTfvarsChanges returns a change per tfvars key fed by a differing attribute. Keys that different
+// resources want set to different values are left alone and returned as conflicts
+func proposeTfvarsChanges(plan *tfjson.Plan, tfvars map[string]interface{}) ([]TfvarsChange, []string) {
+	proposed := map[string]TfvarsChange{}
+	conflicted := map[string]bool{}
+	for _, resourceChange := range plan.ResourceChanges {
+		if resourceChange.Change == nil || planAction(resourceChange.Change.Actions) == "" {
+			continue
+		}
+		before, _ := resourceChange.Change.Before.(map[string]interface{})
+		for _, attribute := range changedAttributes(resourceChange.Change) {
+			changes := listTfvarsChanges(resourceChange, before, attribute, tfvars)
+			key, ok := convergeRule(resourceChange.Type, resourceChange.Name, attribute)
+			if ok && before[attribute] != nil && !reflect.DeepEqual(tfvars[key], before[attribute]) {
+				changes = append(changes, TfvarsChange{Key: key, Old: tfvars[key], New: before[attribute], Source: resourceChange.Address + "." + attribute})
+			}
+			for _, change := range changes {
+				if existing, ok := proposed[change.Key]; ok && !reflect.DeepEqual(existing.New, change.New) {
+					conflicted[change.Key] = true
+					continue
+				}
+				proposed[change.Key] = change
+			}
+		}
+	}
+
+	changes := []TfvarsChange{}
+	conflicts := []string{}
+	for key, change := range proposed {
+		if conflicted[key] {
+			conflicts = append(conflicts, key)
+			continue
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	sort.Strings(conflicts)
+	return changes, conflicts
+}
+
+// listTfvarsChanges returns a change per list rule fed by the attribute of the resource instance, the object
+// at its count index in the tfvars list gets the value from the imported state
+func listTfvarsChanges(resourceChange *tfjson.ResourceChange, before map[string]interface{}, attribute string, tfvars map[string]interface{}) []TfvarsChange {
+	index, ok := resourceChange.Index.(float64)
+	if !ok {
+		return nil
+	}
+	changes := []TfvarsChange{}
+	for ruleKey, rule := range convergeListRules {
+		ruleAttribute, ok := strings.CutPrefix(ruleKey, resourceChange.Type+"."+resourceChange.Name+".")
+		if !ok {
+			continue
+		}
+		topAttribute, mapKey, isMapKey := strings.Cut(ruleAttribute, ".")
+		if topAttribute != attribute {
+			continue
+		}
+		value := before[attribute]
+		if isMapKey {
+			values, _ := value.(map[string]interface{})
+			value = values[mapKey]
+		}
+		items, _ := tfvars[rule.Key].([]interface{})
+		if value == nil || int(index) >= len(items) {
+			continue
+		}
+		item, _ := items[int(index)].(map[string]interface{})
+		if reflect.DeepEqual(item[rule.Field], value) {
+			continue
+		}
+		rule := rule
+		changes = append(changes, TfvarsChange{
+			Key:      fmt.Sprintf("%s[%d].%s", rule.Key, int(index), rule.Field),
+			Old:      item[rule.Field],
+			New:      value,
+			Source:   resourceChange.Address + "." + ruleAttribute,
+			listRule: &rule,
+			index:    int(index),
+		})
+	}
+	return changes
+}
+
+// setTfvar applies the change to the tfvars, a list field is set on the object in the list
+func setTfvar(tfvars map[string]interface{}, change TfvarsChange) {
+	if change.listRule == nil {
+		tfvars[change.Key] = change.New
+		return
+	}
+	items, _ := tfvars[change.listRule.Key].([]interface{})
+	if item, ok := items[change.index].(map[string]interface{}); ok {
+		item[change.listRule.Field] = change.New
+	}
+}
+
+func convergeRule(resourceType string, name string, attribute string) (string, bool) {
+	if key, ok := convergeRules[resourceType+"."+name+"."+attribute]; ok {
+		return key, true
+	}
+	key, ok := convergeRules[resourceType+"."+attribute]
+	return key, ok
+}
+
+func readTfvars(workingDir string) (map[string]interface{}, error) {
+	tfvarsJson, err := os.ReadFile(filepath.Join(workingDir, tfvarsFileName))
+	if err != nil {
+		return nil, fmt.Errorf("%s is needed to converge, run --genvars first: %w", tfvarsFileName, err)
+	}
+	tfvars := map[string]interface{}{}
+	if err = json.Unmarshal(tfvarsJson, &tfvars); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", tfvarsFileName, err)
+	}
+	return tfvars, nil
+}
+
+func writeTfvars(workingDir string, tfvars map[string]interface{}) error {
+	tfvarsJson, err := json.Marshal(tfvars)
+	if err != nil {
+		return err
+	}
+	out := bytes.Buffer{}
+	json.Indent(&out, tfvarsJson, "", "    ")
+	return os.WriteFile(filepath.Join(workingDir, tfvarsFileName), out.Bytes(), 0644)
+}
+
+// PrintConvergeResult writes the tfvars values converge changed followed by the diffs it couldn't resolve
+func PrintConvergeResult(out io.Writer, result ConvergeResult) {
+	if len(result.Changes) > 0 {
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TFVAR\tOLD\tNEW\tFROM\t")
+		for _, change := range result.Changes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", change.Key, jsonValue(change.Old), jsonValue(change.New), change.Source)
+		}
+		w.Flush()
+		fmt.Fprintln(out)
+	}
+	for _, key := range result.Conflicts {
+		fmt.Fprintf(out, "%s not changed, resources in the plan disagree on its value\n", key)
+	}
+	fmt.Fprintf(out, "After %d rounds:\n", result.Rounds)
+	PrintPlannedChanges(out, result.Remaining)
+}
+
+func jsonValue(value interface{}) string {
+	if value == nil {
+		return "-"
+	}
+	valueJson, _ := json.Marshal(value)
+	return string(valueJson)
+}
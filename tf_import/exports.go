@@ -0,0 +1,153 @@
+package tf_import
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"vpc-import-cli/common"
+)
+
+// formats WriteExports can replace the stack's exports with
+const (
+	ExportFormatOutput = "output"
+	ExportFormatSsm    = "ssm"
+)
+
+// the module path of an address, ex. module.vpc. or module.spoke["dev"].
+var modulePathRegexp = regexp.MustCompile(`^(module\.[A-Za-z0-9_-]+(\[[^\]]*\])?\.)+`)
+
+// StackExport is a stack output with an export name. Address is the imported resource whose id is the
+// output's value, empty when the value isn't a resource id ex. a cidr
+type StackExport struct {
+	OutputKey       string
+	ExportName      string
+	Value           string
+	Address         string
+	ImportingStacks []string
+}
+
+// ListStackExports returns the stack's exported outputs with the stacks that import each of them, an export
+// that's imported keeps the stack from being deleted and its value from being changed
+func ListStackExports(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping Mapping,
+	stackName_p *string) ([]StackExport, error) {
+
+	stacksOutput_p, err := common.GetStacksOutput(cfn_client_p, stackName_p)
+	if err != nil {
+		return nil, err
+	}
+	importTargets, _, err := LoadImportTargets(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p)
+	if err != nil {
+		return nil, err
+	}
+	physicalIdsToAddresses := map[string]string{}
+	for _, target := range importTargets {
+		if target.PhysicalId != "" {
+			physicalIdsToAddresses[target.PhysicalId] = target.Address
+		}
+	}
+
+	exports := []StackExport{}
+	for _, output := range stacksOutput_p.Stacks[0].Outputs {
+		if output.ExportName == nil {
+			continue
+		}
+		export := StackExport{
+			OutputKey:  aws.ToString(output.OutputKey),
+			ExportName: *output.ExportName,
+			Value:      aws.ToString(output.OutputValue),
+		}
+		export.Address = physicalIdsToAddresses[export.Value]
+		if export.ImportingStacks, err = common.ListImportingStacks(cfn_client_p, export.ExportName); err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+	return exports, nil
+}
+
+// WriteExports writes a terraform output block, or with ExportFormatSsm an aws_ssm_parameter named ssmPrefix
+// followed by the export name, per export. The references are relative to the module holding the imported
+// resources so the file goes in that module, ex. modules/vpc/exports.tf
+func WriteExports(path string, exports []StackExport, format string, ssmPrefix string) error {
+	if format != ExportFormatOutput && format != ExportFormatSsm {
+		return fmt.Errorf("unknown exports format %s, expected %s or %s", format, ExportFormatOutput, ExportFormatSsm)
+	}
+	f, err := os.Create(path) // Note: This operation truncates an existing file
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	log.Println("Writing exports to Path: " + f.Name())
+	w := bufio.NewWriter(f)
+	for i, export := range exports {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		value := fmt.Sprintf("%q", export.Value)
+		if export.Address != "" {
+			value = moduleRelativeAddress(export.Address) + ".id"
+		} else {
+			fmt.Fprintf(w, "# %s isn't the id of an imported resource, replace the literal with the attribute it comes from\n", export.OutputKey)
+		}
+		if format == ExportFormatOutput {
+			fmt.Fprintf(w, "output %q {\n", export.OutputKey)
+			fmt.Fprintf(w, "  description = %q\n", "Replaces cloudformation export "+export.ExportName)
+			fmt.Fprintf(w, "  value       = %s\n", value)
+			fmt.Fprintln(w, "}")
+			continue
+		}
+		fmt.Fprintf(w, "resource \"aws_ssm_parameter\" %q {\n", "export_"+snakeCase(export.OutputKey))
+		fmt.Fprintf(w, "  name        = %q\n", ssmPrefix+export.ExportName)
+		fmt.Fprintf(w, "  description = %q\n", "Replaces cloudformation export "+export.ExportName)
+		fmt.Fprintln(w, "  type        = \"String\"")
+		fmt.Fprintf(w, "  value       = %s\n", value)
+		fmt.Fprintln(w, "}")
+	}
+	return w.Flush()
+}
+
+// snakeCase turns an output key into a terraform name, ex. Subnet1Id to subnet1_id
+func snakeCase(value string) string {
+	var b strings.Builder
+	runes := []rune(value)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// moduleRelativeAddress drops the module path, for references from inside the module
+func moduleRelativeAddress(address string) string {
+	return modulePathRegexp.ReplaceAllString(address, "")
+}
+
+// PrintExports writes a table of the exports and the stacks importing them
+func PrintExports(out io.Writer, exports []StackExport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EXPORT\tOUTPUT\tVALUE\tTERRAFORM ADDRESS\tIMPORTED BY\t")
+	importedCount := 0
+	for _, export := range exports {
+		if len(export.ImportingStacks) > 0 {
+			importedCount++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", export.ExportName, export.OutputKey, export.Value, valueOrDash(export.Address), valueOrDash(strings.Join(export.ImportingStacks, ", ")))
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d exports, %d imported by other stacks - the stack can't be deleted or change their values until those stacks stop importing them\n", len(exports), importedCount)
+}
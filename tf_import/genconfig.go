@@ -0,0 +1,351 @@
+package tf_import
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"vpc-import-cli/common"
+)
+
+const (
+	// genconfigScratchDir is the root module terraform generates config in, config can't be generated for
+	// resources inside a module
+	genconfigScratchDir     = ".genconfig"
+	genconfigGeneratedFile  = "generated.tf"
+	genconfigModuleFileName = "main.tf"
+	genconfigVarsFileName   = "variables.tf"
+)
+
+var (
+	// resource "aws_vpc" "main" {
+	resourceBlockRegexp = regexp.MustCompile(`^resource "([^"]+)" "([^"]+)" \{$`)
+	// a single line attribute, terraform aligns the = of consecutive attributes
+	attributeLineRegexp = regexp.MustCompile(`^(\s+)([a-z0-9_]+)(\s*=\s*)(.+)$`)
+	// the count index of an address mapped by resource_type, ex. [0]
+	countIndexRegexp = regexp.MustCompile(`\[(\d+)\]$`)
+)
+
+// GenerateModuleConfig writes real HCL for every mapped address to outDir/main.tf, a starting point to replace
+// the empty resource blocks of modules/vpc. terraform 1.5+ generates the config from the live resources with
+// plan -generate-config-out in a scratch root module, then ids of other mapped resources are rewritten to a
+// reference to them, tags to var.tags, and values fed by a tfvars key to var.<key> using the converge rules or
+// else an exact match on a tfvars value. An address mapped by resource_type gets a single block with count, its
+// config generated from instance 0. The variables used are declared in outDir/variables.tf
+func GenerateModuleConfig(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping Mapping,
+	stackName_p *string,
+	workingDir string,
+	outDir string) error {
+
+	tfvars, err := readTfvars(workingDir)
+	if err != nil {
+		return err
+	}
+	importTargets, _, err := LoadImportTargets(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p)
+	if err != nil {
+		return err
+	}
+
+	// the scratch module has no resource blocks, so the addresses lose their module path and instance key.
+	// Instances indexed by count share the config generated for instance 0
+	scratchTargets := []ImportTarget{}
+	seen := map[string]bool{}
+	physicalIdsToAddresses := map[string]string{}
+	instanceCounts := map[string]int{}
+	for _, target := range importableTargets(importTargets) {
+		relativeAddress := moduleRelativeAddress(target.Address)
+		address := instanceKeyRegexp.ReplaceAllString(relativeAddress, "")
+		if countIndexRegexp.MatchString(relativeAddress) {
+			instanceCounts[address]++
+			if target.PhysicalId != "" {
+				physicalIdsToAddresses[target.PhysicalId] = relativeAddress
+			}
+			if !strings.HasSuffix(relativeAddress, "[0]") {
+				continue
+			}
+		} else if address != relativeAddress {
+			log.Printf("Generating config for %s without its instance key, add for_each back by hand", target.Address)
+		}
+		if seen[address] {
+			log.Printf("Skipping %s, config for %s is already generated", target.Address, address)
+			continue
+		}
+		seen[address] = true
+		if target.PhysicalId != "" && physicalIdsToAddresses[target.PhysicalId] == "" {
+			physicalIdsToAddresses[target.PhysicalId] = address
+		}
+		target.Address = address
+		scratchTargets = append(scratchTargets, target)
+	}
+
+	generated, err := terraformGenerateConfig(workingDir, scratchTargets)
+	if err != nil {
+		return err
+	}
+	config, variables := rewriteGeneratedConfig(generated, tfvars, physicalIdsToAddresses, instanceCounts)
+
+	if err = os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	log.Println("Writing generated module to Path: " + filepath.Join(outDir, genconfigModuleFileName))
+	if err = os.WriteFile(filepath.Join(outDir, genconfigModuleFileName), config, 0644); err != nil {
+		return err
+	}
+	return writeVariablesFile(filepath.Join(outDir, genconfigVarsFileName), variables, tfvars)
+}
+
+// terraformGenerateConfig runs plan -generate-config-out for the targets in a fresh scratch directory under
+// workingDir with the working directory's provider config, and returns the generated file
+func terraformGenerateConfig(workingDir string, targets []ImportTarget) ([]byte, error) {
+	scratchDir := filepath.Join(workingDir, genconfigScratchDir)
+	if err := os.RemoveAll(scratchDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(scratchDir, 0755); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"providers.tf", providerOverrideFileName} {
+		content, err := os.ReadFile(filepath.Join(workingDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = os.WriteFile(filepath.Join(scratchDir, name), content, 0644); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeImportBlocksToFile(scratchDir, targets); err != nil {
+		return nil, err
+	}
+
+	tf, err := terraformInit(scratchDir, importBlocksTerraformVersion)
+	if err != nil {
+		return nil, err
+	}
+	// tfexec has no option for -generate-config-out, so terraform is run directly
+	log.Println("Running terraform plan -generate-config-out...")
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(context.Background(), tf.ExecPath(), "plan", "-input=false", "-generate-config-out="+genconfigGeneratedFile)
+	cmd.Dir = scratchDir
+	cmd.Stderr = &stderr
+	planErr := cmd.Run()
+
+	// the plan fails when the generated config doesn't validate, ex. conflicting arguments, but the file
+	// is still written and is fixed up by hand like the rest
+	generated, err := os.ReadFile(filepath.Join(scratchDir, genconfigGeneratedFile))
+	if err != nil {
+		return nil, fmt.Errorf("running terraform plan -generate-config-out: %w: %s", errors.Join(planErr, err), stderr.String())
+	}
+	if planErr != nil {
+		log.Printf("terraform plan failed after generating config, fix the errors it reports in the generated module: %s", stderr.String())
+	}
+	return generated, nil
+}
+
+// rewriteGeneratedConfig rewrites literal values to references to other resources and var.<key>, adds count to
+// the blocks in instanceCounts, and returns the new config with the tfvars keys it references
+func rewriteGeneratedConfig(generated []byte, tfvars map[string]interface{}, physicalIdsToAddresses map[string]string, instanceCounts map[string]int) ([]byte, []string) {
+	// values that are a single tfvars value, for the fallback match
+	valueKeys := map[string][]string{}
+	for key, value := range tfvars {
+		if rendered, ok := hclValue(value); ok {
+			valueKeys[rendered] = append(valueKeys[rendered], key)
+		}
+	}
+
+	variables := map[string]bool{}
+	references := countedReferences(generated, physicalIdsToAddresses, instanceCounts)
+	var out bytes.Buffer
+	resourceType, resourceName := "", ""
+	counted := false
+	// closing line of a tags map being replaced by var.tags
+	skipUntil := ""
+	scanner := bufio.NewScanner(bytes.NewReader(generated))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if skipUntil != "" {
+			if line == skipUntil {
+				skipUntil = ""
+			}
+			continue
+		}
+		if match := resourceBlockRegexp.FindStringSubmatch(line); match != nil {
+			resourceType, resourceName = match[1], match[2]
+			address := resourceType + "." + resourceName
+			_, counted = instanceCounts[address]
+			if counted {
+				out.WriteString(line + "\n")
+				out.WriteString("  count = " + countExpression(address, references[address], tfvars, instanceCounts, variables) + "\n\n")
+				continue
+			}
+		}
+		if match := attributeLineRegexp.FindStringSubmatch(line); match != nil && resourceType != "" {
+			indent, attribute, equals, value := match[1], match[2], match[3], match[4]
+			listRule, isListRule := convergeListRules[resourceType+"."+resourceName+"."+attribute]
+			nameRule, hasNameRule := convergeListRules[resourceType+"."+resourceName+".tags.Name"]
+			if address, ok := physicalIdsToAddresses[strings.Trim(value, `"`)]; ok && countIndexRegexp.ReplaceAllString(address, "") != resourceType+"."+resourceName {
+				if counted {
+					address = countIndexRegexp.ReplaceAllString(address, "[count.index]")
+				}
+				value = address + ".id"
+			} else if attribute == "tags" && value == "{" && tfvars["tags"] != nil {
+				variables["tags"] = true
+				value = "var.tags"
+				if counted && hasNameRule && tfvars[nameRule.Key] != nil {
+					variables[nameRule.Key] = true
+					value = fmt.Sprintf("merge(var.tags, { Name = var.%s[count.index].%s })", nameRule.Key, nameRule.Field)
+				}
+				skipUntil = indent + "}"
+			} else if counted && isListRule && tfvars[listRule.Key] != nil {
+				variables[listRule.Key] = true
+				value = fmt.Sprintf("var.%s[count.index].%s", listRule.Key, listRule.Field)
+			} else if key, ok := convergeRule(resourceType, resourceName, attribute); ok && tfvars[key] != nil {
+				variables[key] = true
+				value = "var." + key
+			} else if keys := valueKeys[value]; len(keys) == 1 {
+				variables[keys[0]] = true
+				value = "var." + keys[0]
+			}
+			line = indent + attribute + equals + value
+		}
+		out.WriteString(line + "\n")
+	}
+
+	keys := []string{}
+	for key := range variables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return out.Bytes(), keys
+}
+
+// countedReferences returns the first counted resource each counted block references, ex. the subnet of a
+// route table association
+func countedReferences(generated []byte, physicalIdsToAddresses map[string]string, instanceCounts map[string]int) map[string]string {
+	references := map[string]string{}
+	address := ""
+	scanner := bufio.NewScanner(bytes.NewReader(generated))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := resourceBlockRegexp.FindStringSubmatch(line); match != nil {
+			address = match[1] + "." + match[2]
+		}
+		match := attributeLineRegexp.FindStringSubmatch(line)
+		if match == nil || references[address] != "" {
+			continue
+		}
+		if _, ok := instanceCounts[address]; !ok {
+			continue
+		}
+		referenced := countIndexRegexp.ReplaceAllString(physicalIdsToAddresses[strings.Trim(match[4], `"`)], "")
+		if _, ok := instanceCounts[referenced]; ok && referenced != address {
+			references[address] = referenced
+		}
+	}
+	return references
+}
+
+// countExpression is length(var.<key>) of the tfvars list a converge list rule feeds the resource from, or
+// else feeds the counted resource it references. Without either it's the number of instances in the stack
+func countExpression(address string, referenced string, tfvars map[string]interface{}, instanceCounts map[string]int, variables map[string]bool) string {
+	for _, candidate := range []string{address, referenced} {
+		for ruleKey, rule := range convergeListRules {
+			if candidate != "" && strings.HasPrefix(ruleKey, candidate+".") && tfvars[rule.Key] != nil {
+				variables[rule.Key] = true
+				return "length(var." + rule.Key + ")"
+			}
+		}
+	}
+	log.Printf("No tfvars list feeds %s, generating it with count = %d", address, instanceCounts[address])
+	return fmt.Sprint(instanceCounts[address])
+}
+
+// hclValue renders a tfvars string or list of strings the way terraform writes it in generated config
+func hclValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return "", false
+		}
+		quoted, _ := json.Marshal(v)
+		return string(quoted), true
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", false
+			}
+			quoted, _ := json.Marshal(s)
+			items = append(items, string(quoted))
+		}
+		return "[" + strings.Join(items, ", ") + "]", true
+	}
+	return "", false
+}
+
+// writeVariablesFile declares the variables, typed from their value in tfvars
+func writeVariablesFile(path string, variables []string, tfvars map[string]interface{}) error {
+	f, err := os.Create(path) // Note: This operation truncates an existing file
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	log.Println("Writing variables to Path: " + f.Name())
+	w := bufio.NewWriter(f)
+	for i, key := range variables {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "variable %q {\n  type = %s\n}\n", key, variableType(tfvars[key]))
+	}
+	return w.Flush()
+}
+
+// variableType is the terraform type of a tfvars value, a list of objects is typed from its first object
+func variableType(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		if len(v) > 0 {
+			if item, ok := v[0].(map[string]interface{}); ok {
+				return "list(" + objectType(item) + ")"
+			}
+		}
+		return "list(string)"
+	case map[string]interface{}:
+		return "map(string)"
+	}
+	return "string"
+}
+
+func objectType(item map[string]interface{}) string {
+	fields := []string{}
+	for field, value := range item {
+		fieldType := "string"
+		switch value.(type) {
+		case bool:
+			fieldType = "bool"
+		case float64:
+			fieldType = "number"
+		}
+		fields = append(fields, field+" = "+fieldType)
+	}
+	sort.Strings(fields)
+	return "object({ " + strings.Join(fields, ", ") + " })"
+}
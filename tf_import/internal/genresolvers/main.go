@@ -0,0 +1,140 @@
+// genresolvers reads the checked-in snapshot of the aws provider's documented import id formats and
+// emits the import id formats and compose functions used by the tf_import resolvers.
+// Run through go generate in the tf_import package.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"go/format"
+	"go/token"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+type providerSnapshot struct {
+	Provider  string             `json:"provider"`
+	Version   string             `json:"version"`
+	Resources []resourceSnapshot `json:"resources"`
+}
+
+type resourceSnapshot struct {
+	Type       string `json:"type"`
+	ImportId   string `json:"import_id"`
+	PhysicalId bool   `json:"physical_id"`
+	Doc        string `json:"doc"`
+}
+
+// template data for a single resource type
+type resourceFormat struct {
+	resourceSnapshot
+	Parts    []string
+	FuncName string
+	Params   []string
+	// go expression joining Params into the import id
+	ComposeExpr string
+}
+
+var partRegexp = regexp.MustCompile(`\{([a-z0-9_]+)\}`)
+
+var outputTemplate = template.Must(template.New("resolvers_gen").Parse(`// Code generated by genresolvers from {{ .Input }}; DO NOT EDIT.
+// Snapshot of {{ .Snapshot.Provider }} {{ .Snapshot.Version }} import documentation.
+
+package tf_import
+
+// generatedImportIdFormats is the import id format documented by the aws provider for each resource type
+var generatedImportIdFormats = map[string]importIdFormat{
+{{- range .Resources }}
+	"{{ .Type }}": {Format: "{{ .ImportId }}", Parts: []string{ {{- range $i, $p := .Parts }}{{ if $i }}, {{ end }}"{{ $p }}"{{ end -}} }, PhysicalId: {{ .PhysicalId }}, Doc: "{{ .Doc }}"},
+{{- end }}
+}
+{{ range .Resources }}{{ if gt (len .Parts) 1 }}
+// {{ .FuncName }} composes the {{ .Type }} import id "{{ .ImportId }}", see
+// {{ .Doc }}
+func {{ .FuncName }}({{ range $i, $p := .Params }}{{ if $i }}, {{ end }}{{ $p }}{{ end }} string) string {
+	return {{ .ComposeExpr }}
+}
+{{ end }}{{ end }}`))
+
+func main() {
+	input := flag.String("in", "provider_import_ids.json", "snapshot of the provider's import id formats")
+	output := flag.String("out", "resolvers_gen.go", "generated go file")
+	flag.Parse()
+
+	snapshotJson, err := os.ReadFile(*input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var snapshot providerSnapshot
+	if err = json.Unmarshal(snapshotJson, &snapshot); err != nil {
+		log.Fatal(err)
+	}
+
+	resources := []resourceFormat{}
+	for _, resource := range snapshot.Resources {
+		resources = append(resources, newResourceFormat(resource))
+	}
+
+	var out bytes.Buffer
+	err = outputTemplate.Execute(&out, map[string]any{
+		"Input":     *input,
+		"Snapshot":  snapshot,
+		"Resources": resources,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatalf("error formatting generated code: %s\n%s", err, out.String())
+	}
+	if err = os.WriteFile(*output, formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newResourceFormat(resource resourceSnapshot) resourceFormat {
+	resourceFormat := resourceFormat{
+		resourceSnapshot: resource,
+		FuncName:         "compose" + camelCase(resource.Type, true) + "ImportId",
+	}
+
+	// the import id is split into literal separators and {part} placeholders, which become
+	// the compose function's params in order
+	literals := partRegexp.Split(resource.ImportId, -1)
+	exprs := []string{}
+	for i, match := range partRegexp.FindAllStringSubmatch(resource.ImportId, -1) {
+		part := match[1]
+		param := camelCase(part, false)
+		if token.Lookup(param).IsKeyword() {
+			param += "Value"
+		}
+		resourceFormat.Parts = append(resourceFormat.Parts, part)
+		resourceFormat.Params = append(resourceFormat.Params, param)
+		if literals[i] != "" {
+			exprs = append(exprs, `"`+literals[i]+`"`)
+		}
+		exprs = append(exprs, param)
+	}
+	if last := literals[len(literals)-1]; last != "" {
+		exprs = append(exprs, `"`+last+`"`)
+	}
+	resourceFormat.ComposeExpr = strings.Join(exprs, " + ")
+	return resourceFormat
+}
+
+// camelCase converts a snake_case name, ex. route_table_id -> routeTableId
+func camelCase(name string, upperFirst bool) string {
+	words := strings.Split(name, "_")
+	for i, word := range words {
+		if word == "" || (i == 0 && !upperFirst) {
+			continue
+		}
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, "")
+}
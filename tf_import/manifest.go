@@ -0,0 +1,189 @@
+package tf_import
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// ManifestFileName is written to the terraform working directory by every import run
+const ManifestFileName = "import-manifest.json"
+
+// manifest entry statuses
+const (
+	StatusImported = "imported"
+	StatusFailed   = "failed"
+	// the address was already in terraform state when --resume looked, so this tool didn't import it
+	StatusAlreadyInState = "already_in_state"
+	// removed from terraform state by the rollback command
+	StatusRolledBack = "rolled_back"
+)
+
+// Manifest records what each import run did to terraform state, one entry per address, so a failed run can be
+// resumed and a bad one rolled back
+type Manifest struct {
+	Entries []ManifestEntry `json:"entries"`
+}
+
+type ManifestEntry struct {
+	Stack     string    `json:"stack"`
+	LogicalId string    `json:"logical_id,omitempty"`
+	Address   string    `json:"address"`
+	ImportId  string    `json:"import_id"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// LoadManifest reads the manifest at path, a missing file is an empty manifest
+func LoadManifest(path string) (*Manifest, error) {
+	manifest := &Manifest{Entries: []ManifestEntry{}}
+	manifestJson, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(manifestJson, manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+func (manifest *Manifest) Save(path string) error {
+	manifestJson, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, manifestJson, 0644)
+}
+
+// Record adds the entry, replacing any earlier entry for the same stack and address. A failed entry doesn't
+// replace an imported or already_in_state one, ex. terraform failing with "Resource already managed" on a
+// rerun, the address is still in state and rollback still has to find it
+func (manifest *Manifest) Record(entry ManifestEntry) {
+	for i, existing := range manifest.Entries {
+		if existing.Stack == entry.Stack && existing.Address == entry.Address {
+			if entry.Status == StatusFailed && (existing.Status == StatusImported || existing.Status == StatusAlreadyInState) {
+				return
+			}
+			manifest.Entries[i] = entry
+			return
+		}
+	}
+	manifest.Entries = append(manifest.Entries, entry)
+}
+
+// Entry returns the stack's entry for the address
+func (manifest *Manifest) Entry(stackName string, address string) (ManifestEntry, bool) {
+	for _, entry := range manifest.Entries {
+		if entry.Stack == stackName && entry.Address == address {
+			return entry, true
+		}
+	}
+	return ManifestEntry{}, false
+}
+
+// HasStack is true when the manifest has an entry of any status for the stack
+func (manifest *Manifest) HasStack(stackName string) bool {
+	for _, entry := range manifest.Entries {
+		if entry.Stack == stackName {
+			return true
+		}
+	}
+	return false
+}
+
+// EntriesForStack returns the stack's entries with the given status
+func (manifest *Manifest) EntriesForStack(stackName string, status string) []ManifestEntry {
+	entries := []ManifestEntry{}
+	for _, entry := range manifest.Entries {
+		if entry.Stack == stackName && entry.Status == status {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func newManifestEntry(stackName string, result ImportResult) ManifestEntry {
+	entry := ManifestEntry{
+		Stack:     stackName,
+		LogicalId: result.Target.LogicalId,
+		Address:   result.Target.Address,
+		ImportId:  result.Target.ImportId,
+		Status:    StatusImported,
+		Timestamp: time.Now().UTC(),
+	}
+	if result.Err != nil {
+		entry.Status = StatusFailed
+		entry.Error = result.Err.Error()
+	}
+	return entry
+}
+
+// resumeTargets splits targets into those still to import and those a previous run already imported or that
+// are already in terraform state. Addresses found in state but not in the manifest are recorded as already_in_state
+func resumeTargets(tf *tfexec.Terraform, manifest *Manifest, stackName string, importTargets []ImportTarget) ([]ImportTarget, []ImportTarget, error) {
+	stateAddresses, err := terraformStateAddresses(tf)
+	if err != nil {
+		return nil, nil, err
+	}
+	imported := map[string]bool{}
+	for _, entry := range manifest.EntriesForStack(stackName, StatusImported) {
+		imported[entry.Address] = true
+	}
+
+	remaining := []ImportTarget{}
+	skipped := []ImportTarget{}
+	for _, target := range importTargets {
+		switch {
+		case imported[target.Address]:
+			skipped = append(skipped, target)
+		case stateAddresses[target.Address]:
+			skipped = append(skipped, target)
+			manifest.Record(ManifestEntry{
+				Stack:     stackName,
+				LogicalId: target.LogicalId,
+				Address:   target.Address,
+				ImportId:  target.ImportId,
+				Status:    StatusAlreadyInState,
+				Timestamp: time.Now().UTC(),
+			})
+		default:
+			remaining = append(remaining, target)
+		}
+	}
+	return remaining, skipped, nil
+}
+
+// terraformStateAddresses is the equivalent of terraform state list
+func terraformStateAddresses(tf *tfexec.Terraform) (map[string]bool, error) {
+	state, err := tf.Show(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("reading terraform state: %w", err)
+	}
+	addresses := map[string]bool{}
+	if state.Values != nil {
+		addModuleAddresses(state.Values.RootModule, addresses)
+	}
+	return addresses, nil
+}
+
+func addModuleAddresses(module *tfjson.StateModule, addresses map[string]bool) {
+	if module == nil {
+		return
+	}
+	for _, resource := range module.Resources {
+		addresses[resource.Address] = true
+	}
+	for _, childModule := range module.ChildModules {
+		addModuleAddresses(childModule, addresses)
+	}
+}
@@ -0,0 +1,80 @@
+package tf_import
+
+import (
+	"reflect"
+	"testing"
+
+	"vpc-import-cli/fake"
+)
+
+func TestManifestRecordAndRollback(t *testing.T) {
+	const (
+		stackName = "networking-dedicated-spoke-dev"
+		address   = "module.vpc.aws_vpc.main"
+	)
+
+	tests := []struct {
+		name         string
+		statuses     []string
+		wantStatus   string
+		wantRollback []string
+	}{
+		{
+			name:         "imported",
+			statuses:     []string{StatusImported},
+			wantStatus:   StatusImported,
+			wantRollback: []string{address},
+		},
+		{
+			name:         "imported then failed keeps the import",
+			statuses:     []string{StatusImported, StatusFailed},
+			wantStatus:   StatusImported,
+			wantRollback: []string{address},
+		},
+		{
+			name:         "already in state then failed is never rolled back",
+			statuses:     []string{StatusAlreadyInState, StatusFailed},
+			wantStatus:   StatusAlreadyInState,
+			wantRollback: []string{},
+		},
+		{
+			name:         "failed then imported",
+			statuses:     []string{StatusFailed, StatusImported},
+			wantStatus:   StatusImported,
+			wantRollback: []string{address},
+		},
+		{
+			name:         "imported then rolled back",
+			statuses:     []string{StatusImported, StatusRolledBack},
+			wantStatus:   StatusRolledBack,
+			wantRollback: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &Manifest{Entries: []ManifestEntry{}}
+			for _, status := range tt.statuses {
+				manifest.Record(ManifestEntry{Stack: stackName, Address: address, ImportId: "vpc-0a1b2c3d4e5f60001", Status: status})
+			}
+			entry, ok := manifest.Entry(stackName, address)
+			if !ok || entry.Status != tt.wantStatus {
+				t.Errorf("Record() status = %q, want %q", entry.Status, tt.wantStatus)
+			}
+
+			// the manifest has entries for the stack, so the fixture is only there for the mapping fallback
+			client := fake.LoadFixture(t)
+			mapping, err := LoadMapping("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := stackName
+			addresses, err := rollbackAddresses(client, client, client, mapping, &name, manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(addresses, tt.wantRollback) {
+				t.Errorf("rollbackAddresses() = %v, want %v", addresses, tt.wantRollback)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package tf_import
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfn_types "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"gopkg.in/yaml.v3"
+
+	"vpc-import-cli/common"
+)
+
+// default mapping for networking-dedicated-spoke stacks, used when --mapping isn't passed
+//
+//go:embed mappings/networking-dedicated-spoke.yaml
+var defaultMappingYaml []byte
+
+// Mapping declares which cloudformation resources are imported to which terraform addresses,
+// and how the id passed to terraform import is built for each of them
+type Mapping struct {
+	// ModuleAddress is a text/template replacing the module path of every address, ex. module.spoke["{{.StackName}}"]
+	// for a root module hosting many vpcs. Empty keeps the addresses as written, --module-address overrides it
+	ModuleAddress string            `yaml:"module_address"`
+	Resources     []ResourceMapping `yaml:"resources"`
+}
+
+type ResourceMapping struct {
+	// LogicalId is empty for resources managed by the terraform module that aren't part of the stack
+	LogicalId string `yaml:"logical_id"`
+	// ResourceType is set instead of LogicalId to map every stack resource of the cloudformation type, ex.
+	// AWS::EC2::Subnet, each to the address indexed by its position in logical id order unless InstanceKey is set.
+	// Subnet route table associations take the index of their subnet
+	ResourceType string `yaml:"resource_type"`
+	Address      string `yaml:"address"`
+	// ImportId is a text/template, see mappings/networking-dedicated-spoke.yaml for the available functions.
+	// When empty the import id comes from the resolver registered for the address's resource type
+	ImportId string `yaml:"import_id"`
+	// InstanceKey is a text/template of the for_each key the resource is imported to, appended to the address
+	// as ["<key>"]. Empty for resources without for_each
+	InstanceKey string `yaml:"instance_key"`
+}
+
+// data passed to each import_id template
+type importIdTemplateData struct {
+	LogicalId  string
+	PhysicalId string
+}
+
+// data passed to the module_address and instance_key templates, Index is the resource's position among the
+// stack resources a resource_type entry matches
+type addressTemplateData struct {
+	StackName  string
+	LogicalId  string
+	PhysicalId string
+	Index      int
+}
+
+// stackResourceMapping is a mapping entry for a single stack resource, entries with a resource_type are
+// expanded to one per matching resource with LogicalId set
+type stackResourceMapping struct {
+	ResourceMapping
+	Index int
+}
+
+// LoadMapping reads a YAML (or JSON) mapping file, or returns the embedded default mapping if path is empty
+func LoadMapping(path string) (Mapping, error) {
+	mappingYaml := defaultMappingYaml
+	if path != "" {
+		var err error
+		mappingYaml, err = os.ReadFile(path)
+		if err != nil {
+			return Mapping{}, err
+		}
+	}
+
+	var mapping Mapping
+	if err := yaml.Unmarshal(mappingYaml, &mapping); err != nil {
+		return Mapping{}, fmt.Errorf("parsing mapping %s: %w", path, err)
+	}
+
+	for _, resource := range mapping.Resources {
+		if resource.Address == "" {
+			return Mapping{}, errors.New("mapping.go: LoadMapping(path string): resource is missing an address in mapping: " + path)
+		}
+		if resource.LogicalId != "" && resource.ResourceType != "" {
+			return Mapping{}, errors.New("mapping.go: LoadMapping(path string): only one of logical_id or resource_type can be set for address: " + resource.Address)
+		}
+		if resource.LogicalId == "" && resource.ResourceType == "" && resource.ImportId == "" {
+			return Mapping{}, errors.New("mapping.go: LoadMapping(path string): import_id is required when logical_id and resource_type are empty for address: " + resource.Address)
+		}
+		if resource.ResourceType != "" && strings.HasSuffix(resource.Address, "]") {
+			return Mapping{}, errors.New("mapping.go: LoadMapping(path string): an address mapped by resource_type is indexed per resource, it can't have an instance key: " + resource.Address)
+		}
+		if resource.InstanceKey != "" && strings.HasSuffix(resource.Address, "]") {
+			return Mapping{}, errors.New("mapping.go: LoadMapping(path string): instance_key is set but the address already has an instance key: " + resource.Address)
+		}
+	}
+	return mapping, nil
+}
+
+// stackAddress is the address the resource is imported to for the stack, with the module path replaced by
+// the rendered module_address and the rendered instance_key appended
+func (mapping Mapping) stackAddress(resource ResourceMapping, data addressTemplateData) (string, error) {
+	address := resource.Address
+	if mapping.ModuleAddress != "" {
+		moduleAddress, err := renderAddressTemplate("module_address", mapping.ModuleAddress, data)
+		if err != nil {
+			return "", err
+		}
+		address = moduleAddress + "." + moduleRelativeAddress(address)
+	}
+	if resource.InstanceKey != "" {
+		key, err := renderAddressTemplate("instance_key of "+resource.Address, resource.InstanceKey, data)
+		if err != nil {
+			return "", err
+		}
+		address += fmt.Sprintf("[%q]", key)
+	} else if resource.ResourceType != "" {
+		address += fmt.Sprintf("[%d]", data.Index)
+	}
+	return address, nil
+}
+
+func renderAddressTemplate(name string, text string, data addressTemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s: %w", name, err)
+	}
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("rendering %s: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+// mapsResource is true when an entry maps the stack resource, by its logical id or its type
+func (mapping Mapping) mapsResource(logicalId string, resourceType string) bool {
+	for _, resource := range mapping.Resources {
+		if resource.LogicalId == logicalId || (resource.ResourceType != "" && resource.ResourceType == resourceType) {
+			return true
+		}
+	}
+	return false
+}
+
+// stackResources expands the resource_type entries to an entry per stack resource of the type, indexed in
+// logical id order. Subnet route table associations are indexed by their subnet instead, the vpc module
+// associates aws_subnet.this[count.index] so any other index would replace the association
+func (mapping Mapping) stackResources(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, ec2_client_p common.VpcDescriber) ([]stackResourceMapping, error) {
+	resources := []stackResourceMapping{}
+	for _, resource := range mapping.Resources {
+		if resource.ResourceType == "" {
+			resources = append(resources, stackResourceMapping{ResourceMapping: resource})
+			continue
+		}
+		indexes := map[int]string{}
+		for index, stackResource := range common.StackResourcesByType(stackResourcesOutput_p, resource.ResourceType) {
+			expanded := resource
+			expanded.LogicalId = aws.ToString(stackResource.LogicalResourceId)
+			if resource.ResourceType == "AWS::EC2::SubnetRouteTableAssociation" {
+				var err error
+				if index, err = associationSubnetIndex(stackResourcesOutput_p, ec2_client_p, stackResource); err != nil {
+					return nil, err
+				}
+			}
+			if other, ok := indexes[index]; ok {
+				return nil, fmt.Errorf("%s and %s both map to %s[%d]", other, expanded.LogicalId, resource.Address, index)
+			}
+			indexes[index] = expanded.LogicalId
+			resources = append(resources, stackResourceMapping{ResourceMapping: expanded, Index: index})
+		}
+	}
+	return resources, nil
+}
+
+// associationSubnetIndex is the index of the association's subnet among the stack's subnets
+func associationSubnetIndex(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, ec2_client_p common.VpcDescriber, association cfn_types.StackResource) (int, error) {
+	routeTableAssociation, err := getRouteTableAssociation(ec2_client_p, aws.ToString(association.PhysicalResourceId))
+	if err != nil {
+		return 0, err
+	}
+	subnetId := aws.ToString(routeTableAssociation.SubnetId)
+	for index, subnet := range common.StackResourcesByType(stackResourcesOutput_p, "AWS::EC2::Subnet") {
+		if aws.ToString(subnet.PhysicalResourceId) == subnetId {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: subnet %s of route table association %s isn't in the stack", common.ErrResourceNotFound, subnetId, aws.ToString(association.LogicalResourceId))
+}
+
+// renderImportId executes the resource's import_id template against the stack
+func renderImportId(resource ResourceMapping,
+	funcs template.FuncMap,
+	logicalIdsToPhysicalIds map[string]string) (string, error) {
+
+	tmpl, err := template.New(resource.Address).Option("missingkey=error").Funcs(funcs).Parse(resource.ImportId)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, importIdTemplateData{
+		LogicalId:  resource.LogicalId,
+		PhysicalId: logicalIdsToPhysicalIds[resource.LogicalId],
+	})
+	return out.String(), err
+}
+
+// importIdFuncs returns the functions available to import_id templates, each input that resolves empty is
+// appended to emptyInputs so an import id composed from it isn't taken for a valid one
+func importIdFuncs(ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	stacksOutput_p cloudformation.DescribeStacksOutput,
+	logicalIdsToPhysicalIds map[string]string,
+	emptyInputs *[]string) template.FuncMap {
+
+	recordEmpty := func(value string, input string) string {
+		if value == "" {
+			*emptyInputs = append(*emptyInputs, input)
+		}
+		return value
+	}
+	return template.FuncMap{
+		"physicalId": func(logicalId string) string {
+			return recordEmpty(logicalIdsToPhysicalIds[logicalId], fmt.Sprintf("physicalId %q", logicalId))
+		},
+		"param": func(paramKey string) (string, error) {
+			value, err := common.GetParameterValue(stacksOutput_p, paramKey)
+			return recordEmpty(value, fmt.Sprintf("param %q", paramKey)), err
+		},
+		"resolvedParam": func(paramKey string) (string, error) {
+			value, err := common.GetParameterResolvedValue(stacksOutput_p, paramKey)
+			return recordEmpty(value, fmt.Sprintf("resolvedParam %q", paramKey)), err
+		},
+		"liveParam": func(paramKey string) (string, error) {
+			value, err := liveParameterValue(ec2_client_p, stacksOutput_p, logicalIdsToPhysicalIds, paramKey)
+			return recordEmpty(value, fmt.Sprintf("liveParam %q", paramKey)), err
+		},
+		// composes an import id from the format documented by the aws provider for the resource type
+		"importId": func(tfResourceType string, values ...string) (string, error) {
+			format, ok := generatedImportIdFormats[tfResourceType]
+			if !ok {
+				return "", errors.New("no import id format known for resource type: " + tfResourceType)
+			}
+			for i, value := range values {
+				if i < len(format.Parts) {
+					recordEmpty(value, "{"+format.Parts[i]+"}")
+				}
+			}
+			return format.compose(values...)
+		},
+		// tries each resolver rule name in order, so an iac managed rule can be preferred over its legacy equivalent
+		"resolverRuleAssociationId": func(vpcId string, resolverRuleNames ...string) (string, error) {
+			assoc, err := common.GetFirstResolverRuleAssociation(route53resolver_client_p, vpcId, resolverRuleNames...)
+			if err != nil || assoc == nil {
+				return "", err
+			}
+			return *assoc.Id, nil
+		},
+	}
+}
+
+// liveParameterValue returns the live value of the resource the stack parameter configured, the same one genvars
+// writes to tfvars, falling back to the parameter when the resource doesn't have one, ex. an attachment that
+// isn't associated
+func liveParameterValue(ec2_client_p common.VpcDescriber,
+	stacksOutput_p cloudformation.DescribeStacksOutput,
+	logicalIdsToPhysicalIds map[string]string,
+	paramKey string) (string, error) {
+
+	// like genvars, the resolved value of ssm parameters and the value of the others
+	param, err := common.GetParameter(stacksOutput_p, paramKey)
+	if err != nil {
+		return "", err
+	}
+	parameterValue := aws.ToString(param.ParameterValue)
+	if param.ResolvedValue != nil {
+		parameterValue = *param.ResolvedValue
+	}
+	vpcId, tgwAttachmentId := logicalIdsToPhysicalIds["VPC"], logicalIdsToPhysicalIds["TgwAttach"]
+	liveValue := ""
+	switch paramKey {
+	case "IpRange":
+		if vpcId != "" {
+			liveValue, err = common.GetVpcRange(ec2_client_p, vpcId)
+		}
+	case "TgwRouteTableID":
+		if tgwAttachmentId != "" {
+			liveValue, err = common.GetTgwAttachmentAssociatedRouteTableId(ec2_client_p, tgwAttachmentId)
+		}
+	case "TgwMSKRouteTableID":
+		if tgwAttachmentId != "" {
+			var associatedRouteTableId string
+			associatedRouteTableId, err = liveParameterValue(ec2_client_p, stacksOutput_p, logicalIdsToPhysicalIds, "TgwRouteTableID")
+			if err == nil {
+				liveValue, err = common.GetTgwAttachmentMSKRouteTableId(ec2_client_p, tgwAttachmentId, associatedRouteTableId)
+			}
+		}
+	default:
+		return "", errors.New("no live value known for parameter: " + paramKey)
+	}
+	if err != nil || liveValue == "" {
+		return parameterValue, err
+	}
+	return liveValue, nil
+}
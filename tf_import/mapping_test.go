@@ -0,0 +1,102 @@
+package tf_import
+
+import (
+	"reflect"
+	"testing"
+
+	"vpc-import-cli/common"
+	"vpc-import-cli/fake"
+)
+
+func TestRenderImportId(t *testing.T) {
+	client := fake.LoadFixture(t)
+	stackName := "networking-dedicated-spoke-dev"
+	stacksOutput_p, err := common.GetStacksOutput(client, &stackName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logicalIdsToPhysicalIds := map[string]string{
+		"VPC":        "vpc-0a1b2c3d4e5f60001",
+		"RouteTable": "rtb-0a1b2c3d4e5f60001",
+		"TgwAttach":  "tgw-attach-0a1b2c3d4e5f60001",
+	}
+
+	tests := []struct {
+		name            string
+		resource        ResourceMapping
+		want            string
+		wantEmptyInputs []string
+		wantErr         bool
+	}{
+		{
+			name:     "physical id of the resource",
+			resource: ResourceMapping{LogicalId: "VPC", ImportId: "{{ .PhysicalId }}"},
+			want:     "vpc-0a1b2c3d4e5f60001",
+		},
+		{
+			name:     "composed from a physical id and a literal",
+			resource: ResourceMapping{LogicalId: "Route", ImportId: `{{ importId "aws_route" (physicalId "RouteTable") "0.0.0.0/0" }}`},
+			want:     "rtb-0a1b2c3d4e5f60001_0.0.0.0/0",
+		},
+		{
+			name:     "composed from a resolved parameter and a parameter",
+			resource: ResourceMapping{LogicalId: "TgwRoute", ImportId: `{{ importId "aws_ec2_transit_gateway_route" (resolvedParam "TgwRouteTableID") (param "IpRange") }}`},
+			want:     "tgw-rtb-0a1b2c3d4e5f60001_10.20.0.0/22",
+		},
+		{
+			name:     "msk route table from the live propagations",
+			resource: ResourceMapping{LogicalId: "TgwMSKAttachmentPropagation", ImportId: `{{ importId "aws_ec2_transit_gateway_route_table_propagation" (liveParam "TgwMSKRouteTableID") (physicalId "TgwAttach") }}`},
+			want:     "tgw-rtb-0a1b2c3d4e5f60002_tgw-attach-0a1b2c3d4e5f60001",
+		},
+		{
+			name:     "resolver rule association preferring the first rule found",
+			resource: ResourceMapping{ImportId: `{{ resolverRuleAssociationId (physicalId "VPC") "hccp-mskcc-tld-rule" "MSKCC TLD" }}`},
+			want:     "rslvr-rrassoc-0a1b2c3d4e5f60002",
+		},
+		{
+			name:            "physical id missing from the stack",
+			resource:        ResourceMapping{LogicalId: "TgwRouteAssocation", ImportId: `{{ importId "aws_ec2_transit_gateway_route_table_association" (resolvedParam "TgwRouteTableID") (physicalId "TgwAttachMissing") }}`},
+			want:            "tgw-rtb-0a1b2c3d4e5f60001_",
+			wantEmptyInputs: []string{`physicalId "TgwAttachMissing"`, "{transit_gateway_attachment_id}"},
+		},
+		{
+			name:     "wrong number of values",
+			resource: ResourceMapping{LogicalId: "Route", ImportId: `{{ importId "aws_route" (physicalId "RouteTable") }}`},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown resource type",
+			resource: ResourceMapping{LogicalId: "Route", ImportId: `{{ importId "aws_not_a_type" "a" }}`},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown parameter",
+			resource: ResourceMapping{LogicalId: "TgwRoute", ImportId: `{{ param "NotAParameter" }}`},
+			wantErr:  true,
+		},
+		{
+			name:     "parameter without a live value",
+			resource: ResourceMapping{LogicalId: "TgwRoute", ImportId: `{{ liveParam "SubnetCidrBits" }}`},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var emptyInputs []string
+			funcs := importIdFuncs(client, client, *stacksOutput_p, logicalIdsToPhysicalIds, &emptyInputs)
+			got, err := renderImportId(tt.resource, funcs, logicalIdsToPhysicalIds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderImportId() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("renderImportId() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(emptyInputs, tt.wantEmptyInputs) {
+				t.Errorf("renderImportId() empty inputs = %v, want %v", emptyInputs, tt.wantEmptyInputs)
+			}
+		})
+	}
+}
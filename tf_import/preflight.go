@@ -0,0 +1,159 @@
+package tf_import
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfn_types "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+
+	"vpc-import-cli/common"
+)
+
+// how often and for how long drift detection is polled
+var (
+	driftPollInterval = 5 * time.Second
+	driftPollTimeout  = 5 * time.Minute
+)
+
+// driftedTfvars maps the cloudformation properties set from stack parameters to the tfvars key genvars
+// fills from the same parameter, drift on one of these means the tfvars value differs from the stack's
+var driftedTfvars = map[string]string{
+	"AWS::EC2::VPC/CidrBlock":                                                  "IpRange",
+	"AWS::EC2::DHCPOptions/DomainName":                                         "DomainName",
+	"AWS::EC2::DHCPOptions/DomainNameServers":                                  "DomainNameServers",
+	"AWS::EC2::TransitGatewayAttachment/TransitGatewayId":                      "TransitGatewayID",
+	"AWS::EC2::TransitGatewayAttachment/Options/DnsSupport":                    "tgw_attachment_dns_support",
+	"AWS::EC2::TransitGatewayRouteTableAssociation/TransitGatewayRouteTableId": "TgwRouteTableID",
+	"AWS::EC2::TransitGatewayRouteTablePropagation/TransitGatewayRouteTableId": "TgwRouteTableID",
+	"AWS::EC2::TransitGatewayRoute/TransitGatewayRouteTableId":                 "TgwRouteTableID",
+}
+
+// drift on a list property is reported per element, ex. /DomainNameServers/0
+var listIndexRegexp = regexp.MustCompile(`/[0-9]+$`)
+
+// PropertyDrift is a single drifted property of a mapped stack resource, PropertyPath is empty when the
+// whole resource was deleted. TfVar is set when genvars fills a tfvars key from the property
+type PropertyDrift struct {
+	LogicalId    string
+	ResourceType string
+	Status       string
+	PropertyPath string
+	Expected     string
+	Actual       string
+	TfVar        string
+}
+
+// Preflight refuses stacks in an unstable status, then runs cloudformation drift detection and returns the
+// drift on resources the mapping imports. Drift is an error wrapping common.ErrStackDrifted unless allowDrift is set
+func Preflight(cfn_client_p common.StackDescriber, mapping Mapping, stackName_p *string, allowDrift bool) ([]PropertyDrift, error) {
+	stacksOutput_p, err := common.GetStacksOutput(cfn_client_p, stackName_p)
+	if err != nil {
+		return nil, err
+	}
+	status := stacksOutput_p.Stacks[0].StackStatus
+	if !common.IsStableStackStatus(status) {
+		return nil, fmt.Errorf("%w: %s is %s", common.ErrStackUnstable, *stackName_p, status)
+	}
+
+	if err = detectStackDrift(cfn_client_p, stackName_p); err != nil {
+		return nil, err
+	}
+	drifts, err := mappedResourceDrifts(cfn_client_p, mapping, stackName_p)
+	if err != nil {
+		return nil, err
+	}
+	if len(drifts) > 0 && !allowDrift {
+		return drifts, fmt.Errorf("%w: %d drifted properties on imported resources of %s, set --allow-drift to import anyway", common.ErrStackDrifted, len(drifts), *stackName_p)
+	}
+	return drifts, nil
+}
+
+// detectStackDrift starts drift detection and waits for it to finish
+func detectStackDrift(cfn_client_p common.StackDescriber, stackName_p *string) error {
+	log.Printf("Detecting drift on stack %s...", *stackName_p)
+	detectOutput, err := cfn_client_p.DetectStackDrift(context.TODO(), &cloudformation.DetectStackDriftInput{StackName: stackName_p})
+	if err != nil {
+		return fmt.Errorf("detecting drift on stack %s: %w", *stackName_p, err)
+	}
+
+	deadline := time.Now().Add(driftPollTimeout)
+	for {
+		statusInput := cloudformation.DescribeStackDriftDetectionStatusInput{StackDriftDetectionId: detectOutput.StackDriftDetectionId}
+		statusOutput, err := cfn_client_p.DescribeStackDriftDetectionStatus(context.TODO(), &statusInput)
+		if err != nil {
+			return fmt.Errorf("describing drift detection on stack %s: %w", *stackName_p, err)
+		}
+		switch statusOutput.DetectionStatus {
+		case cfn_types.StackDriftDetectionStatusDetectionComplete:
+			return nil
+		case cfn_types.StackDriftDetectionStatusDetectionFailed:
+			// some resources don't support drift detection, the rest still have results
+			log.Printf("Drift detection on stack %s failed for some resources: %s", *stackName_p, aws.ToString(statusOutput.DetectionStatusReason))
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("drift detection on stack %s didn't finish within %s", *stackName_p, driftPollTimeout)
+		}
+		time.Sleep(driftPollInterval)
+	}
+}
+
+func mappedResourceDrifts(cfn_client_p common.StackDescriber, mapping Mapping, stackName_p *string) ([]PropertyDrift, error) {
+	drifts := []PropertyDrift{}
+	input := cloudformation.DescribeStackResourceDriftsInput{
+		StackName: stackName_p,
+		StackResourceDriftStatusFilters: []cfn_types.StackResourceDriftStatus{
+			cfn_types.StackResourceDriftStatusModified,
+			cfn_types.StackResourceDriftStatusDeleted,
+		},
+	}
+	for {
+		output, err := cfn_client_p.DescribeStackResourceDrifts(context.TODO(), &input)
+		if err != nil {
+			return nil, fmt.Errorf("describing resource drift of stack %s: %w", *stackName_p, err)
+		}
+		for _, resourceDrift := range output.StackResourceDrifts {
+			logicalId := aws.ToString(resourceDrift.LogicalResourceId)
+			if !mapping.mapsResource(logicalId, aws.ToString(resourceDrift.ResourceType)) {
+				continue
+			}
+			drift := PropertyDrift{
+				LogicalId:    logicalId,
+				ResourceType: aws.ToString(resourceDrift.ResourceType),
+				Status:       string(resourceDrift.StackResourceDriftStatus),
+			}
+			if len(resourceDrift.PropertyDifferences) == 0 {
+				drifts = append(drifts, drift)
+			}
+			for _, difference := range resourceDrift.PropertyDifferences {
+				drift.PropertyPath = aws.ToString(difference.PropertyPath)
+				drift.Expected = aws.ToString(difference.ExpectedValue)
+				drift.Actual = aws.ToString(difference.ActualValue)
+				drift.TfVar = driftedTfvars[drift.ResourceType+listIndexRegexp.ReplaceAllString(drift.PropertyPath, "")]
+				drifts = append(drifts, drift)
+			}
+		}
+		if output.NextToken == nil {
+			return drifts, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// PrintDrift writes a table of the drifted properties, with the tfvars key genvars fills from each where there is one
+func PrintDrift(out io.Writer, drifts []PropertyDrift) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LOGICAL ID\tSTATUS\tPROPERTY\tSTACK VALUE\tLIVE VALUE\tTFVAR\t")
+	for _, drift := range drifts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", drift.LogicalId, drift.Status, valueOrDash(drift.PropertyPath), valueOrDash(drift.Expected), valueOrDash(drift.Actual), valueOrDash(drift.TfVar))
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d drifted properties, genvars writes the live value for the tfvars listed above\n", len(drifts))
+}
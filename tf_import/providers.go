@@ -0,0 +1,37 @@
+package tf_import
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// providerOverrideFileName is merged by terraform over the provider block in providers.tf
+const providerOverrideFileName = "providers_override.tf"
+
+// WriteProviderOverride writes a providers_override.tf to workingDir pointing the aws provider at the region
+// and role the stack was read with, so terraform imports from the same account and region instead of the
+// us-east-1 in providers.tf. The region is always written, an empty roleArn leaves the provider's credentials alone
+func WriteProviderOverride(workingDir string, region string, roleArn string) error {
+	if region == "" {
+		return errors.New("no aws region to write to the provider override")
+	}
+	f, err := os.Create(filepath.Join(workingDir, providerOverrideFileName)) // Note: This operation truncates an existing file
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	log.Println("Writing provider override to Path: " + f.Name())
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "provider \"aws\" {")
+	fmt.Fprintf(w, "  region = %q\n", region)
+	if roleArn != "" {
+		fmt.Fprintf(w, "\n  assume_role {\n    role_arn = %q\n  }\n", roleArn)
+	}
+	fmt.Fprintln(w, "}")
+	return w.Flush()
+}
@@ -0,0 +1,280 @@
+package tf_import
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+
+	"vpc-import-cli/common"
+)
+
+// AddressMove is an address that changed between two mappings, relative to the module. A From without an
+// instance key moves every instance of the resource, keeping their keys
+type AddressMove struct {
+	LogicalId string
+	From      string
+	To        string
+}
+
+// RemapAddresses pairs the resources of the old and new mapping, by logical id or else by import_id for
+// resources outside the stack, and returns the addresses that moved. Resources only in one of the mappings
+// are logged, there's nothing to move them to. An instance_key template the two mappings don't share can't be
+// resolved without a stack and is an error.
+// A resource_type entry the other mapping doesn't also map by resource_type, ex. aws_subnet.this replacing
+// subnet_1..3, is paired with the other mapping's logical ids of that cloudformation type at the index
+// an import indexes them. That needs the resources of a stack built from the same template, when
+// stackResourcesOutput_p is nil such an entry is logged and not moved
+func RemapAddresses(oldMapping Mapping,
+	newMapping Mapping,
+	ec2_client_p common.VpcDescriber,
+	stackResourcesOutput_p *cloudformation.DescribeStackResourcesOutput) ([]AddressMove, error) {
+
+	oldResources, err := remapResources(oldMapping, newMapping, ec2_client_p, stackResourcesOutput_p)
+	if err != nil {
+		return nil, err
+	}
+	newResourceList, err := remapResources(newMapping, oldMapping, ec2_client_p, stackResourcesOutput_p)
+	if err != nil {
+		return nil, err
+	}
+	newResources := map[string]remapResource{}
+	for _, resource := range newResourceList {
+		newResources[remapKey(resource.ResourceMapping)] = resource
+	}
+
+	moves := []AddressMove{}
+	paired := map[string]bool{}
+	for _, oldResource := range oldResources {
+		key := remapKey(oldResource.ResourceMapping)
+		newResource, ok := newResources[key]
+		if !ok {
+			log.Printf("%s isn't in the new mapping, not moving it%s", oldResource.Address, oldResource.unpairedHint())
+			continue
+		}
+		paired[key] = true
+
+		from, to := moduleRelativeAddress(oldResource.Address), moduleRelativeAddress(newResource.Address)
+		// the same instance_key on both sides renders the same keys, moving the whole resource keeps them
+		if oldResource.indexed || newResource.indexed || oldResource.InstanceKey != newResource.InstanceKey {
+			if oldResource.ResourceType != "" && !oldResource.indexed {
+				return nil, fmt.Errorf("%s is indexed per %s resource in the stack, remap can only move it when both mappings have the same instance_key", oldResource.Address, oldResource.ResourceType)
+			}
+			var err error
+			if from, err = oldResource.instanceAddress(from); err != nil {
+				return nil, err
+			}
+			if to, err = newResource.instanceAddress(to); err != nil {
+				return nil, err
+			}
+		}
+		if from != to {
+			moves = append(moves, AddressMove{LogicalId: oldResource.LogicalId, From: from, To: to})
+		}
+	}
+	for _, resource := range newResourceList {
+		if !paired[remapKey(resource.ResourceMapping)] {
+			log.Printf("%s is new in the mapping, import it%s", resource.Address, resource.unpairedHint())
+		}
+	}
+
+	// terraform state mv runs one move at a time, a move onto an address another move vacates would collide
+	froms := map[string]bool{}
+	for _, move := range moves {
+		froms[move.From] = true
+	}
+	for _, move := range moves {
+		if froms[move.To] {
+			return nil, fmt.Errorf("%s moves to %s, which another resource moves from, split the remap in two", move.From, move.To)
+		}
+	}
+	sort.Slice(moves, func(i, j int) bool { return moves[i].From < moves[j].From })
+	return moves, nil
+}
+
+// remapResource is a mapping entry, or with indexed set one stack resource of a resource_type entry
+type remapResource struct {
+	ResourceMapping
+	indexed bool
+	index   int
+}
+
+// remapResources expands the mapping's resource_type entries to an entry per stack resource of the type, unless
+// the other mapping maps the type by resource_type too and the entries pair as they are
+func remapResources(mapping Mapping,
+	other Mapping,
+	ec2_client_p common.VpcDescriber,
+	stackResourcesOutput_p *cloudformation.DescribeStackResourcesOutput) ([]remapResource, error) {
+
+	otherTypes := map[string]bool{}
+	for _, resource := range other.Resources {
+		if resource.ResourceType != "" {
+			otherTypes[resource.ResourceType] = true
+		}
+	}
+	resources := []remapResource{}
+	for _, resource := range mapping.Resources {
+		if resource.ResourceType == "" || otherTypes[resource.ResourceType] || stackResourcesOutput_p == nil {
+			resources = append(resources, remapResource{ResourceMapping: resource})
+			continue
+		}
+		expanded := mapping
+		expanded.Resources = []ResourceMapping{resource}
+		stackResources, err := expanded.stackResources(*stackResourcesOutput_p, ec2_client_p)
+		if err != nil {
+			return nil, err
+		}
+		for _, stackResource := range stackResources {
+			resources = append(resources, remapResource{ResourceMapping: stackResource.ResourceMapping, indexed: true, index: stackResource.Index})
+		}
+	}
+	return resources, nil
+}
+
+func (resource remapResource) instanceAddress(address string) (string, error) {
+	if resource.indexed && resource.InstanceKey == "" {
+		return fmt.Sprintf("%s[%d]", address, resource.index), nil
+	}
+	return staticInstanceAddress(address, resource.InstanceKey)
+}
+
+func (resource remapResource) unpairedHint() string {
+	if resource.ResourceType != "" && !resource.indexed {
+		return ", pass --stack-name to pair it with the other mapping's logical ids of type " + resource.ResourceType
+	}
+	return ""
+}
+
+func remapKey(resource ResourceMapping) string {
+	if resource.LogicalId != "" {
+		return "logical_id:" + resource.LogicalId
+	}
+	if resource.ResourceType != "" {
+		return "resource_type:" + resource.ResourceType
+	}
+	return "import_id:" + resource.ImportId
+}
+
+func staticInstanceAddress(address string, instanceKey string) (string, error) {
+	if instanceKey == "" {
+		return address, nil
+	}
+	if strings.Contains(instanceKey, "{{") {
+		return "", fmt.Errorf("instance_key of %s is a template that renders per stack, remap can only move it when both mappings have the same instance_key", address)
+	}
+	return fmt.Sprintf("%s[%q]", address, instanceKey), nil
+}
+
+// apply returns where the move takes a state address, which can be in any module instance
+func (move AddressMove) apply(address string) (string, bool) {
+	relative := moduleRelativeAddress(address)
+	modulePath := strings.TrimSuffix(address, relative)
+	if relative == move.From {
+		return modulePath + move.To, true
+	}
+	if !strings.HasSuffix(move.From, "]") && strings.HasPrefix(relative, move.From+"[") {
+		return modulePath + move.To + strings.TrimPrefix(relative, move.From), true
+	}
+	return "", false
+}
+
+// WriteMovedBlocks writes a moved block per move. The addresses are relative to the module, so the blocks go in
+// the module, ex. modules/vpc/moved.tf, and move the resources of every instance of it on the next apply
+func WriteMovedBlocks(out io.Writer, moves []AddressMove) error {
+	w := bufio.NewWriter(out)
+	for i, move := range moves {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "moved {\n  from = %s\n  to   = %s\n}\n", move.From, move.To)
+	}
+	return w.Flush()
+}
+
+// TerraformStateMv runs terraform state mv in each working directory for the state addresses the moves
+// apply to, and updates the addresses in its manifest. With dryRun set the moves are printed instead
+func TerraformStateMv(workingDirs []string, moves []AddressMove, dryRun bool) error {
+	var mvErr error
+	for _, workingDir := range workingDirs {
+		if err := terraformStateMvDir(workingDir, moves, dryRun); err != nil {
+			mvErr = errors.Join(mvErr, fmt.Errorf("%s: %w", workingDir, err))
+		}
+	}
+	return mvErr
+}
+
+func terraformStateMvDir(workingDir string, moves []AddressMove, dryRun bool) error {
+	tf, err := terraformInit(workingDir, importTerraformVersion)
+	if err != nil {
+		return err
+	}
+	stateAddresses, err := terraformStateAddresses(tf)
+	if err != nil {
+		return err
+	}
+
+	// a move of a whole resource is one state mv however many instances it has
+	stateMoves := map[string]string{}
+	for address := range stateAddresses {
+		for _, move := range moves {
+			to, ok := move.apply(address)
+			if !ok {
+				continue
+			}
+			if !strings.HasSuffix(move.From, "]") {
+				modulePath := strings.TrimSuffix(address, moduleRelativeAddress(address))
+				stateMoves[modulePath+move.From] = modulePath + move.To
+			} else {
+				stateMoves[address] = to
+			}
+		}
+	}
+	froms := []string{}
+	for from := range stateMoves {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	if len(froms) == 0 {
+		log.Printf("Nothing to move in %s", workingDir)
+		return nil
+	}
+	if dryRun {
+		for _, from := range froms {
+			fmt.Printf("%s: %s -> %s\n", workingDir, from, stateMoves[from])
+		}
+		return nil
+	}
+
+	manifest, err := LoadManifest(filepath.Join(workingDir, ManifestFileName))
+	if err != nil {
+		return err
+	}
+	var mvErr error
+	for _, from := range froms {
+		log.Printf("Moving Resource Address: %s to %s in %s", from, stateMoves[from], workingDir)
+		if err := tf.StateMv(context.Background(), from, stateMoves[from]); err != nil {
+			mvErr = errors.Join(mvErr, fmt.Errorf("moving %s to %s: %w", from, stateMoves[from], err))
+			continue
+		}
+		for i, entry := range manifest.Entries {
+			if entry.Address == from || strings.HasPrefix(entry.Address, from+"[") {
+				manifest.Entries[i].Address = stateMoves[from] + strings.TrimPrefix(entry.Address, from)
+			}
+		}
+	}
+	if len(manifest.Entries) == 0 {
+		return mvErr
+	}
+	if err := manifest.Save(filepath.Join(workingDir, ManifestFileName)); err != nil {
+		mvErr = errors.Join(mvErr, fmt.Errorf("saving manifest: %w", err))
+	}
+	return mvErr
+}
@@ -0,0 +1,182 @@
+package tf_import
+
+//go:generate go run ./internal/genresolvers -in provider_import_ids.json -out resolvers_gen.go
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfn_types "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2_types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"vpc-import-cli/common"
+)
+
+// ResolverInput is everything an ImportIdResolver can use to compute the id terraform import expects
+type ResolverInput struct {
+	// Resource is the stack resource being imported
+	Resource              cfn_types.StackResource
+	StacksOutput          cloudformation.DescribeStacksOutput
+	StackResourcesOutput  cloudformation.DescribeStackResourcesOutput
+	Ec2Client             common.VpcDescriber
+	Route53ResolverClient common.ResolverRuleLister
+}
+
+// ImportIdResolver computes the terraform import id for a single stack resource
+type ImportIdResolver func(input ResolverInput) (string, error)
+
+// registry of import id resolvers keyed by terraform resource type - resource types without a resolver
+// are imported by their cloudformation physical id
+var importIdResolvers = map[string]ImportIdResolver{}
+
+// RegisterImportIdResolver adds or replaces the resolver used for every address of the given terraform resource type
+func RegisterImportIdResolver(tfResourceType string, resolver ImportIdResolver) {
+	importIdResolvers[tfResourceType] = resolver
+}
+
+func init() {
+	RegisterImportIdResolver("aws_vpc_dhcp_options", resolveDhcpOptionsId)
+	RegisterImportIdResolver("aws_vpc_dhcp_options_association", resolveDhcpOptionsAssociationId)
+	RegisterImportIdResolver("aws_default_network_acl", resolveDefaultNetworkAclId)
+	RegisterImportIdResolver("aws_security_group_rule", resolveSecurityGroupRuleId)
+	RegisterImportIdResolver("aws_route_table_association", resolveRouteTableAssociationId)
+}
+
+// importIdFormat is an import id format documented by the aws provider, generated into resolvers_gen.go
+type importIdFormat struct {
+	// Format is the documented import id, ex. {route_table_id}_{destination_cidr_block}
+	Format string
+	Parts  []string
+	// PhysicalId is true when the cloudformation physical id of the equivalent resource is the import id
+	PhysicalId bool
+	Doc        string
+}
+
+// compose replaces each part of the format with the value in the same position
+func (format importIdFormat) compose(values ...string) (string, error) {
+	if len(values) != len(format.Parts) {
+		return "", fmt.Errorf("import id format %s takes %d values, got %d", format.Format, len(format.Parts), len(values))
+	}
+	importId := format.Format
+	for i, part := range format.Parts {
+		importId = strings.Replace(importId, "{"+part+"}", values[i], 1)
+	}
+	return importId, nil
+}
+
+// resolveImportId returns the import id from the resolver registered for the address's resource type,
+// falling back to the physical id. Resource types documented with an import id that isn't the physical
+// id resolve to an empty id, so they're reported rather than imported with the wrong id
+func resolveImportId(address string, input ResolverInput) (string, error) {
+	tfResourceType := resourceTypeFromAddress(address)
+	if resolver, ok := importIdResolvers[tfResourceType]; ok {
+		return resolver(input)
+	}
+	if format, ok := generatedImportIdFormats[tfResourceType]; ok && !format.PhysicalId {
+		log.Printf("No resolver registered for %s, set import_id in the mapping to %s for address: %s, see %s", tfResourceType, format.Format, address, format.Doc)
+		return "", nil
+	}
+	return aws.ToString(input.Resource.PhysicalResourceId), nil
+}
+
+var instanceKeyRegexp = regexp.MustCompile(`\[[^\]]*\]`)
+
+// resourceTypeFromAddress returns the resource type of a terraform address,
+// ex. module.vpc.aws_ec2_transit_gateway_vpc_attachment.main["0"] -> aws_ec2_transit_gateway_vpc_attachment
+func resourceTypeFromAddress(address string) string {
+	parts := strings.Split(instanceKeyRegexp.ReplaceAllString(address, ""), ".")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-2]
+}
+
+// physicalIdsByType returns the physical ids of every stack resource of the given cloudformation resource type
+func (input ResolverInput) physicalIdsByType(cfnResourceType string) []string {
+	physicalIds := []string{}
+	for _, resource := range input.StackResourcesOutput.StackResources {
+		if *resource.ResourceType == cfnResourceType {
+			physicalIds = append(physicalIds, aws.ToString(resource.PhysicalResourceId))
+		}
+	}
+	return physicalIds
+}
+
+// singlePhysicalIdByType returns the physical id of the only stack resource of the given type, it's an error
+// for the stack to have none or more than one
+func (input ResolverInput) singlePhysicalIdByType(cfnResourceType string) (string, error) {
+	physicalIds := input.physicalIdsByType(cfnResourceType)
+	if len(physicalIds) != 1 {
+		return "", fmt.Errorf("expected exactly one %s in stack to resolve %s, found %d", cfnResourceType, *input.Resource.LogicalResourceId, len(physicalIds))
+	}
+	return physicalIds[0], nil
+}
+
+// use the dhcp options associated with the vpc rather than that defined in the stack (in case they are not the same)
+func resolveDhcpOptionsId(input ResolverInput) (string, error) {
+	vpcId, err := input.singlePhysicalIdByType("AWS::EC2::VPC")
+	if err != nil {
+		return "", err
+	}
+	return common.GetDhcpOptionsIdFromVpc(input.Ec2Client, vpcId)
+}
+
+// dhcp options associations are imported by vpc id
+func resolveDhcpOptionsAssociationId(input ResolverInput) (string, error) {
+	return input.singlePhysicalIdByType("AWS::EC2::VPC")
+}
+
+func resolveDefaultNetworkAclId(input ResolverInput) (string, error) {
+	vpcId, err := input.singlePhysicalIdByType("AWS::EC2::VPC")
+	if err != nil {
+		return "", err
+	}
+	return getDefaultNaclIdFromVpc(input.Ec2Client, vpcId)
+}
+
+// security group rules are looked up from the stack's security group, the rule direction comes from the
+// cloudformation resource type
+func resolveSecurityGroupRuleId(input ResolverInput) (string, error) {
+	groupId, err := input.singlePhysicalIdByType("AWS::EC2::SecurityGroup")
+	if err != nil {
+		return "", err
+	}
+	ingressId, egressId, err := getSecurityGroupRulePhysicalIds(input.Ec2Client, groupId)
+	if *input.Resource.ResourceType == "AWS::EC2::SecurityGroupEgress" {
+		return egressId, err
+	}
+	return ingressId, err
+}
+
+// route table associations are imported as subnet_id/route_table_id, see
+// https://registry.terraform.io/providers/hashicorp/aws/latest/docs/resources/route_table_association#import
+func resolveRouteTableAssociationId(input ResolverInput) (string, error) {
+	association, err := getRouteTableAssociation(input.Ec2Client, aws.ToString(input.Resource.PhysicalResourceId))
+	if err != nil {
+		return "", err
+	}
+	return composeAwsRouteTableAssociationImportId(aws.ToString(association.SubnetId), aws.ToString(association.RouteTableId)), nil
+}
+
+func getRouteTableAssociation(ec2_client_p common.VpcDescriber, associationId string) (ec2_types.RouteTableAssociation, error) {
+	filterName := "association.route-table-association-id"
+	filters := []ec2_types.Filter{{Name: &filterName, Values: []string{associationId}}}
+	output, err := ec2_client_p.DescribeRouteTables(context.TODO(), &ec2.DescribeRouteTablesInput{Filters: filters})
+	if err != nil {
+		return ec2_types.RouteTableAssociation{}, fmt.Errorf("describing route table of association %s: %w", associationId, err)
+	}
+	for _, routeTable := range output.RouteTables {
+		for _, association := range routeTable.Associations {
+			if aws.ToString(association.RouteTableAssociationId) == associationId {
+				return association, nil
+			}
+		}
+	}
+	return ec2_types.RouteTableAssociation{}, fmt.Errorf("%w: route table association %s", common.ErrResourceNotFound, associationId)
+}
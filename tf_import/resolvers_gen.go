@@ -0,0 +1,113 @@
+// Code generated by genresolvers from provider_import_ids.json; DO NOT EDIT.
+// Snapshot of registry.terraform.io/hashicorp/aws 4.67.0 import documentation.
+
+package tf_import
+
+// generatedImportIdFormats is the import id format documented by the aws provider for each resource type
+var generatedImportIdFormats = map[string]importIdFormat{
+	"aws_cloudwatch_log_group":                        {Format: "{name}", Parts: []string{"name"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/cloudwatch_log_group#import"},
+	"aws_default_network_acl":                         {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/default_network_acl#import"},
+	"aws_default_route_table":                         {Format: "{vpc_id}", Parts: []string{"vpc_id"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/default_route_table#import"},
+	"aws_default_security_group":                      {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/default_security_group#import"},
+	"aws_ec2_transit_gateway_route":                   {Format: "{transit_gateway_route_table_id}_{destination_cidr_block}", Parts: []string{"transit_gateway_route_table_id", "destination_cidr_block"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ec2_transit_gateway_route#import"},
+	"aws_ec2_transit_gateway_route_table_association": {Format: "{transit_gateway_route_table_id}_{transit_gateway_attachment_id}", Parts: []string{"transit_gateway_route_table_id", "transit_gateway_attachment_id"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ec2_transit_gateway_route_table_association#import"},
+	"aws_ec2_transit_gateway_route_table_propagation": {Format: "{transit_gateway_route_table_id}_{transit_gateway_attachment_id}", Parts: []string{"transit_gateway_route_table_id", "transit_gateway_attachment_id"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ec2_transit_gateway_route_table_propagation#import"},
+	"aws_ec2_transit_gateway_vpc_attachment":          {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ec2_transit_gateway_vpc_attachment#import"},
+	"aws_eip":                                         {Format: "{id}", Parts: []string{"id"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/eip#import"},
+	"aws_flow_log":                                    {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/flow_log#import"},
+	"aws_iam_role":                                    {Format: "{name}", Parts: []string{"name"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/iam_role#import"},
+	"aws_internet_gateway":                            {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/internet_gateway#import"},
+	"aws_internet_gateway_attachment":                 {Format: "{internet_gateway_id}:{vpc_id}", Parts: []string{"internet_gateway_id", "vpc_id"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/internet_gateway_attachment#import"},
+	"aws_nat_gateway":                                 {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/nat_gateway#import"},
+	"aws_network_acl":                                 {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/network_acl#import"},
+	"aws_network_acl_rule":                            {Format: "{network_acl_id}:{rule_number}:{protocol}:{egress}", Parts: []string{"network_acl_id", "rule_number", "protocol", "egress"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/network_acl_rule#import"},
+	"aws_ram_principal_association":                   {Format: "{resource_share_arn},{principal}", Parts: []string{"resource_share_arn", "principal"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ram_principal_association#import"},
+	"aws_ram_resource_association":                    {Format: "{resource_share_arn},{resource_arn}", Parts: []string{"resource_share_arn", "resource_arn"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ram_resource_association#import"},
+	"aws_ram_resource_share":                          {Format: "{arn}", Parts: []string{"arn"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ram_resource_share#import"},
+	"aws_route":                                       {Format: "{route_table_id}_{destination_cidr_block}", Parts: []string{"route_table_id", "destination_cidr_block"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/route#import"},
+	"aws_route53_resolver_rule_association":           {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/route53_resolver_rule_association#import"},
+	"aws_route_table":                                 {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/route_table#import"},
+	"aws_route_table_association":                     {Format: "{subnet_id}/{route_table_id}", Parts: []string{"subnet_id", "route_table_id"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/route_table_association#import"},
+	"aws_security_group":                              {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/security_group#import"},
+	"aws_security_group_rule":                         {Format: "{security_group_id}_{type}_{protocol}_{from_port}_{to_port}_{source}", Parts: []string{"security_group_id", "type", "protocol", "from_port", "to_port", "source"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/security_group_rule#import"},
+	"aws_subnet":                                      {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/subnet#import"},
+	"aws_vpc":                                         {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/vpc#import"},
+	"aws_vpc_dhcp_options":                            {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/vpc_dhcp_options#import"},
+	"aws_vpc_dhcp_options_association":                {Format: "{vpc_id}", Parts: []string{"vpc_id"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/vpc_dhcp_options_association#import"},
+	"aws_vpc_endpoint":                                {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/vpc_endpoint#import"},
+	"aws_vpc_endpoint_route_table_association":        {Format: "{vpc_endpoint_id}/{route_table_id}", Parts: []string{"vpc_endpoint_id", "route_table_id"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/vpc_endpoint_route_table_association#import"},
+	"aws_vpc_endpoint_subnet_association":             {Format: "{vpc_endpoint_id}/{subnet_id}", Parts: []string{"vpc_endpoint_id", "subnet_id"}, PhysicalId: false, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/vpc_endpoint_subnet_association#import"},
+	"aws_vpc_ipv4_cidr_block_association":             {Format: "{id}", Parts: []string{"id"}, PhysicalId: true, Doc: "https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/vpc_ipv4_cidr_block_association#import"},
+}
+
+// composeAwsEc2TransitGatewayRouteImportId composes the aws_ec2_transit_gateway_route import id "{transit_gateway_route_table_id}_{destination_cidr_block}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ec2_transit_gateway_route#import
+func composeAwsEc2TransitGatewayRouteImportId(transitGatewayRouteTableId, destinationCidrBlock string) string {
+	return transitGatewayRouteTableId + "_" + destinationCidrBlock
+}
+
+// composeAwsEc2TransitGatewayRouteTableAssociationImportId composes the aws_ec2_transit_gateway_route_table_association import id "{transit_gateway_route_table_id}_{transit_gateway_attachment_id}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ec2_transit_gateway_route_table_association#import
+func composeAwsEc2TransitGatewayRouteTableAssociationImportId(transitGatewayRouteTableId, transitGatewayAttachmentId string) string {
+	return transitGatewayRouteTableId + "_" + transitGatewayAttachmentId
+}
+
+// composeAwsEc2TransitGatewayRouteTablePropagationImportId composes the aws_ec2_transit_gateway_route_table_propagation import id "{transit_gateway_route_table_id}_{transit_gateway_attachment_id}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ec2_transit_gateway_route_table_propagation#import
+func composeAwsEc2TransitGatewayRouteTablePropagationImportId(transitGatewayRouteTableId, transitGatewayAttachmentId string) string {
+	return transitGatewayRouteTableId + "_" + transitGatewayAttachmentId
+}
+
+// composeAwsInternetGatewayAttachmentImportId composes the aws_internet_gateway_attachment import id "{internet_gateway_id}:{vpc_id}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/internet_gateway_attachment#import
+func composeAwsInternetGatewayAttachmentImportId(internetGatewayId, vpcId string) string {
+	return internetGatewayId + ":" + vpcId
+}
+
+// composeAwsNetworkAclRuleImportId composes the aws_network_acl_rule import id "{network_acl_id}:{rule_number}:{protocol}:{egress}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/network_acl_rule#import
+func composeAwsNetworkAclRuleImportId(networkAclId, ruleNumber, protocol, egress string) string {
+	return networkAclId + ":" + ruleNumber + ":" + protocol + ":" + egress
+}
+
+// composeAwsRamPrincipalAssociationImportId composes the aws_ram_principal_association import id "{resource_share_arn},{principal}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ram_principal_association#import
+func composeAwsRamPrincipalAssociationImportId(resourceShareArn, principal string) string {
+	return resourceShareArn + "," + principal
+}
+
+// composeAwsRamResourceAssociationImportId composes the aws_ram_resource_association import id "{resource_share_arn},{resource_arn}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/ram_resource_association#import
+func composeAwsRamResourceAssociationImportId(resourceShareArn, resourceArn string) string {
+	return resourceShareArn + "," + resourceArn
+}
+
+// composeAwsRouteImportId composes the aws_route import id "{route_table_id}_{destination_cidr_block}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/route#import
+func composeAwsRouteImportId(routeTableId, destinationCidrBlock string) string {
+	return routeTableId + "_" + destinationCidrBlock
+}
+
+// composeAwsRouteTableAssociationImportId composes the aws_route_table_association import id "{subnet_id}/{route_table_id}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/route_table_association#import
+func composeAwsRouteTableAssociationImportId(subnetId, routeTableId string) string {
+	return subnetId + "/" + routeTableId
+}
+
+// composeAwsSecurityGroupRuleImportId composes the aws_security_group_rule import id "{security_group_id}_{type}_{protocol}_{from_port}_{to_port}_{source}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/security_group_rule#import
+func composeAwsSecurityGroupRuleImportId(securityGroupId, typeValue, protocol, fromPort, toPort, source string) string {
+	return securityGroupId + "_" + typeValue + "_" + protocol + "_" + fromPort + "_" + toPort + "_" + source
+}
+
+// composeAwsVpcEndpointRouteTableAssociationImportId composes the aws_vpc_endpoint_route_table_association import id "{vpc_endpoint_id}/{route_table_id}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/vpc_endpoint_route_table_association#import
+func composeAwsVpcEndpointRouteTableAssociationImportId(vpcEndpointId, routeTableId string) string {
+	return vpcEndpointId + "/" + routeTableId
+}
+
+// composeAwsVpcEndpointSubnetAssociationImportId composes the aws_vpc_endpoint_subnet_association import id "{vpc_endpoint_id}/{subnet_id}", see
+// https://registry.terraform.io/providers/hashicorp/aws/4.67.0/docs/resources/vpc_endpoint_subnet_association#import
+func composeAwsVpcEndpointSubnetAssociationImportId(vpcEndpointId, subnetId string) string {
+	return vpcEndpointId + "/" + subnetId
+}
@@ -0,0 +1,389 @@
+package tf_import
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfn_types "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"gopkg.in/yaml.v3"
+
+	"vpc-import-cli/common"
+)
+
+// how often and for how long change sets and stack operations are polled
+var (
+	stackPollInterval = 10 * time.Second
+	stackPollTimeout  = 30 * time.Minute
+)
+
+// cloudformation's limit on an inline TemplateBody, larger templates have to go through s3
+const maxTemplateBodySize = 51200
+
+// retireChangeSetName is the change set that sets DeletionPolicy: Retain
+const retireChangeSetName = "vpc-import-cli-retain"
+
+// RetireStack hands the stack's resources over to terraform for good: it sets DeletionPolicy and
+// UpdateReplacePolicy Retain on every resource the manifest records as in terraform state and applies that with a
+// change set, checks terraform state has every manifest address, then deletes the stack. It refuses when a resource
+// left to be deleted is of a type the mapping imports, and deletes the other resources left only with
+// deleteUnmapped set. confirm is asked before each step that changes the stack, with dryRun set the steps are
+// printed and nothing is changed
+func RetireStack(cfn_client_p common.StackRetirer,
+	mapping Mapping,
+	stackName_p *string,
+	workingDir string,
+	deleteUnmapped bool,
+	dryRun bool,
+	confirm func(step string) bool) error {
+
+	manifest, err := LoadManifest(filepath.Join(workingDir, ManifestFileName))
+	if err != nil {
+		return err
+	}
+	entries := append(manifest.EntriesForStack(*stackName_p, StatusImported), manifest.EntriesForStack(*stackName_p, StatusAlreadyInState)...)
+	if len(entries) == 0 {
+		return fmt.Errorf("%s has no imported addresses for stack %s, import it first", ManifestFileName, *stackName_p)
+	}
+	retainLogicalIds := map[string]bool{}
+	for _, entry := range entries {
+		if entry.LogicalId != "" {
+			retainLogicalIds[entry.LogicalId] = true
+		}
+	}
+
+	if err = checkExportsNotImported(cfn_client_p, stackName_p); err != nil {
+		return err
+	}
+
+	// the state is checked up front so a dry run reports it too, and again before the delete
+	if err = checkStateHasManifestAddresses(workingDir, entries); err != nil {
+		return err
+	}
+
+	templateOutput, err := cfn_client_p.GetTemplate(context.TODO(), &cloudformation.GetTemplateInput{StackName: stackName_p, TemplateStage: cfn_types.TemplateStageOriginal})
+	if err != nil {
+		return fmt.Errorf("getting template of stack %s: %w", *stackName_p, err)
+	}
+	templateBody, deletedLogicalIds, err := retainTemplate(aws.ToString(templateOutput.TemplateBody), retainLogicalIds)
+	if err != nil {
+		return fmt.Errorf("adding DeletionPolicy Retain to the template of stack %s: %w", *stackName_p, err)
+	}
+	resourceTypes, err := templateResourceTypes(aws.ToString(templateOutput.TemplateBody))
+	if err != nil {
+		return fmt.Errorf("reading the resource types of the template of stack %s: %w", *stackName_p, err)
+	}
+	mappedLogicalIds, unmappedLogicalIds := splitMappedResources(mapping, resourceTypes, deletedLogicalIds)
+	if len(mappedLogicalIds) > 0 {
+		return fmt.Errorf("stack %s has resources of a type the mapping imports that weren't imported, import them before retiring the stack: %s", *stackName_p, strings.Join(mappedLogicalIds, ", "))
+	}
+	if len(unmappedLogicalIds) > 0 && !deleteUnmapped {
+		return fmt.Errorf("retiring stack %s would delete resources the mapping doesn't import, pass --delete-unmapped to delete them with the stack: %s", *stackName_p, strings.Join(unmappedLogicalIds, ", "))
+	}
+
+	retainStep := fmt.Sprintf("Set DeletionPolicy: Retain on %d resources of stack %s with a change set", len(retainLogicalIds), *stackName_p)
+	deleteStep := fmt.Sprintf("Delete stack %s", *stackName_p)
+	if len(deletedLogicalIds) > 0 {
+		deleteStep += ", deleting the resources that weren't imported: " + strings.Join(deletedLogicalIds, ", ")
+	}
+	if dryRun {
+		fmt.Println("1. " + retainStep)
+		fmt.Println("2. Check terraform state has every address in " + ManifestFileName)
+		fmt.Println("3. " + deleteStep)
+		return nil
+	}
+
+	if !confirm(retainStep) {
+		return errors.New("retire-stack cancelled")
+	}
+	if err = applyRetainChangeSet(cfn_client_p, stackName_p, templateBody); err != nil {
+		return err
+	}
+
+	if err = checkStateHasManifestAddresses(workingDir, entries); err != nil {
+		return err
+	}
+
+	if !confirm(deleteStep) {
+		return errors.New("retire-stack cancelled")
+	}
+	return deleteStack(cfn_client_p, stackName_p)
+}
+
+func checkStateHasManifestAddresses(workingDir string, entries []ManifestEntry) error {
+	tf, err := terraformInit(workingDir, importTerraformVersion)
+	if err != nil {
+		return err
+	}
+	stateAddresses, err := terraformStateAddresses(tf)
+	if err != nil {
+		return err
+	}
+	missing := []string{}
+	for _, entry := range entries {
+		if !stateAddresses[entry.Address] {
+			missing = append(missing, entry.Address)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("terraform state is missing addresses from %s, not retiring the stack: %s", ManifestFileName, strings.Join(missing, ", "))
+	}
+	log.Printf("Terraform state has all %d addresses from %s", len(entries), ManifestFileName)
+	return nil
+}
+
+// cloudformation refuses to delete a stack while another stack imports one of its exports
+func checkExportsNotImported(cfn_client_p common.StackDescriber, stackName_p *string) error {
+	stacksOutput_p, err := common.GetStacksOutput(cfn_client_p, stackName_p)
+	if err != nil {
+		return err
+	}
+	imported := []string{}
+	for _, output := range stacksOutput_p.Stacks[0].Outputs {
+		if output.ExportName == nil {
+			continue
+		}
+		importingStacks, err := common.ListImportingStacks(cfn_client_p, *output.ExportName)
+		if err != nil {
+			return err
+		}
+		if len(importingStacks) > 0 {
+			imported = append(imported, fmt.Sprintf("%s (imported by %s)", *output.ExportName, strings.Join(importingStacks, ", ")))
+		}
+	}
+	if len(imported) > 0 {
+		return fmt.Errorf("stack %s can't be deleted while its exports are imported, see the exports command: %s", *stackName_p, strings.Join(imported, "; "))
+	}
+	return nil
+}
+
+// retainTemplate sets DeletionPolicy and UpdateReplacePolicy Retain on the resources, it returns the template
+// as YAML (a JSON template is converted) and the logical ids of the resources left to be deleted with the stack
+func retainTemplate(templateBody string, retainLogicalIds map[string]bool) (string, []string, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(templateBody), &document); err != nil {
+		return "", nil, err
+	}
+	if len(document.Content) == 0 {
+		return "", nil, errors.New("template is empty")
+	}
+	resources := mappingValue(document.Content[0], "Resources")
+	if resources == nil || resources.Kind != yaml.MappingNode {
+		return "", nil, errors.New("template has no Resources")
+	}
+
+	deletedLogicalIds := []string{}
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		logicalId, resource := resources.Content[i].Value, resources.Content[i+1]
+		if !retainLogicalIds[logicalId] {
+			if policy := mappingValue(resource, "DeletionPolicy"); policy == nil || policy.Value != "Retain" {
+				deletedLogicalIds = append(deletedLogicalIds, logicalId)
+			}
+			continue
+		}
+		setMappingValue(resource, "DeletionPolicy", "Retain")
+		setMappingValue(resource, "UpdateReplacePolicy", "Retain")
+	}
+	sort.Strings(deletedLogicalIds)
+
+	// a JSON template parses as flow style YAML, block style reads better
+	clearFlowStyle(&document)
+	out, err := yaml.Marshal(&document)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(out) > maxTemplateBodySize {
+		return "", nil, fmt.Errorf("template is %d bytes, over the %d byte limit for a template body", len(out), maxTemplateBodySize)
+	}
+	return string(out), deletedLogicalIds, nil
+}
+
+// templateResourceTypes returns the Type of each resource in the template by logical id
+func templateResourceTypes(templateBody string) (map[string]string, error) {
+	template := struct {
+		Resources map[string]struct {
+			Type string `yaml:"Type"`
+		} `yaml:"Resources"`
+	}{}
+	if err := yaml.Unmarshal([]byte(templateBody), &template); err != nil {
+		return nil, err
+	}
+	resourceTypes := map[string]string{}
+	for logicalId, resource := range template.Resources {
+		resourceTypes[logicalId] = resource.Type
+	}
+	return resourceTypes, nil
+}
+
+// splitMappedResources splits the logical ids into the resources of a type the mapping imports, by resource_type
+// or by the type of a logical_id entry's resource, and the rest
+func splitMappedResources(mapping Mapping, resourceTypes map[string]string, logicalIds []string) ([]string, []string) {
+	mappedTypes := map[string]bool{}
+	for _, resource := range mapping.Resources {
+		if resource.ResourceType != "" {
+			mappedTypes[resource.ResourceType] = true
+		}
+		if resourceType, ok := resourceTypes[resource.LogicalId]; ok && resource.LogicalId != "" {
+			mappedTypes[resourceType] = true
+		}
+	}
+	mapped, unmapped := []string{}, []string{}
+	for _, logicalId := range logicalIds {
+		if mappedTypes[resourceTypes[logicalId]] || mapping.mapsResource(logicalId, resourceTypes[logicalId]) {
+			mapped = append(mapped, logicalId)
+			continue
+		}
+		unmapped = append(unmapped, logicalId)
+	}
+	return mapped, unmapped
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(node *yaml.Node, key string, value string) {
+	if existing := mappingValue(node, key); existing != nil {
+		existing.Kind, existing.Tag, existing.Value, existing.Content = yaml.ScalarNode, "!!str", value, nil
+		return
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
+
+func clearFlowStyle(node *yaml.Node) {
+	node.Style &^= yaml.FlowStyle
+	for _, child := range node.Content {
+		clearFlowStyle(child)
+	}
+}
+
+// applyRetainChangeSet creates the change set with the stack's current parameters, waits for it, then executes it
+// and waits for the update to finish. A change set without changes, ex. from an earlier run, is deleted by
+// cloudformation and counted as done
+func applyRetainChangeSet(cfn_client_p common.StackRetirer, stackName_p *string, templateBody string) error {
+	stacksOutput_p, err := common.GetStacksOutput(cfn_client_p, stackName_p)
+	if err != nil {
+		return err
+	}
+	parameters := []cfn_types.Parameter{}
+	for _, param := range stacksOutput_p.Stacks[0].Parameters {
+		parameters = append(parameters, cfn_types.Parameter{ParameterKey: param.ParameterKey, UsePreviousValue: aws.Bool(true)})
+	}
+
+	changeSetName := aws.String(retireChangeSetName)
+	log.Printf("Creating change set %s on stack %s...", *changeSetName, *stackName_p)
+	_, err = cfn_client_p.CreateChangeSet(context.TODO(), &cloudformation.CreateChangeSetInput{
+		StackName:     stackName_p,
+		ChangeSetName: changeSetName,
+		ChangeSetType: cfn_types.ChangeSetTypeUpdate,
+		TemplateBody:  aws.String(templateBody),
+		Parameters:    parameters,
+		Capabilities: []cfn_types.Capability{
+			cfn_types.CapabilityCapabilityIam,
+			cfn_types.CapabilityCapabilityNamedIam,
+			cfn_types.CapabilityCapabilityAutoExpand,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("creating change set on stack %s: %w", *stackName_p, err)
+	}
+
+	ready, err := waitForChangeSet(cfn_client_p, stackName_p, changeSetName)
+	if err != nil || !ready {
+		return err
+	}
+
+	log.Printf("Executing change set %s on stack %s...", *changeSetName, *stackName_p)
+	if _, err = cfn_client_p.ExecuteChangeSet(context.TODO(), &cloudformation.ExecuteChangeSetInput{StackName: stackName_p, ChangeSetName: changeSetName}); err != nil {
+		return fmt.Errorf("executing change set on stack %s: %w", *stackName_p, err)
+	}
+	return waitForStackStatus(cfn_client_p, stackName_p, cfn_types.StackStatusUpdateComplete)
+}
+
+// waitForChangeSet returns true once the change set can be executed, false if it has no changes. A failed change
+// set is deleted, cloudformation keeps it and creating one with the same name on a rerun would fail
+func waitForChangeSet(cfn_client_p common.StackRetirer, stackName_p *string, changeSetName *string) (bool, error) {
+	deadline := time.Now().Add(stackPollTimeout)
+	for {
+		output, err := cfn_client_p.DescribeChangeSet(context.TODO(), &cloudformation.DescribeChangeSetInput{StackName: stackName_p, ChangeSetName: changeSetName})
+		if err != nil {
+			return false, fmt.Errorf("describing change set on stack %s: %w", *stackName_p, err)
+		}
+		reason := aws.ToString(output.StatusReason)
+		switch output.Status {
+		case cfn_types.ChangeSetStatusCreateComplete:
+			return true, nil
+		case cfn_types.ChangeSetStatusFailed:
+			deleteErr := deleteChangeSet(cfn_client_p, stackName_p, changeSetName)
+			if strings.Contains(reason, "didn't contain changes") || strings.Contains(reason, "No updates are to be performed") {
+				log.Printf("Stack %s already retains its imported resources", *stackName_p)
+				return false, deleteErr
+			}
+			return false, errors.Join(fmt.Errorf("change set on stack %s failed: %s", *stackName_p, reason), deleteErr)
+		}
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("change set on stack %s wasn't created within %s", *stackName_p, stackPollTimeout)
+		}
+		time.Sleep(stackPollInterval)
+	}
+}
+
+func deleteChangeSet(cfn_client_p common.StackRetirer, stackName_p *string, changeSetName *string) error {
+	log.Printf("Deleting change set %s on stack %s...", *changeSetName, *stackName_p)
+	if _, err := cfn_client_p.DeleteChangeSet(context.TODO(), &cloudformation.DeleteChangeSetInput{StackName: stackName_p, ChangeSetName: changeSetName}); err != nil {
+		return fmt.Errorf("deleting change set on stack %s: %w", *stackName_p, err)
+	}
+	return nil
+}
+
+// waitForStackStatus waits until the stack reaches the status, failing if it settles in any other
+func waitForStackStatus(cfn_client_p common.StackDescriber, stackName_p *string, status cfn_types.StackStatus) error {
+	deadline := time.Now().Add(stackPollTimeout)
+	for {
+		stacksOutput_p, err := common.GetStacksOutput(cfn_client_p, stackName_p)
+		if err != nil {
+			return err
+		}
+		current := stacksOutput_p.Stacks[0].StackStatus
+		if current == status {
+			return nil
+		}
+		if !strings.HasSuffix(string(current), "_IN_PROGRESS") {
+			return fmt.Errorf("stack %s is %s, expected %s", *stackName_p, current, status)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("stack %s didn't reach %s within %s", *stackName_p, status, stackPollTimeout)
+		}
+		time.Sleep(stackPollInterval)
+	}
+}
+
+// deleteStack deletes the stack and waits until it's gone
+func deleteStack(cfn_client_p common.StackRetirer, stackName_p *string) error {
+	log.Printf("Deleting stack %s...", *stackName_p)
+	if _, err := cfn_client_p.DeleteStack(context.TODO(), &cloudformation.DeleteStackInput{StackName: stackName_p}); err != nil {
+		return fmt.Errorf("deleting stack %s: %w", *stackName_p, err)
+	}
+	err := waitForStackStatus(cfn_client_p, stackName_p, cfn_types.StackStatusDeleteComplete)
+	if errors.Is(err, common.ErrStackNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package tf_import
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfn_types "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+
+	"vpc-import-cli/fake"
+)
+
+// changeSetClient keeps the change set the way cloudformation does, creating one with the name of a change set
+// that wasn't deleted fails. The rest of the stack is replayed from the fixture
+type changeSetClient struct {
+	*fake.Client
+	status       cfn_types.ChangeSetStatus
+	statusReason string
+	exists       bool
+}
+
+func (c *changeSetClient) CreateChangeSet(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error) {
+	if c.exists {
+		return nil, &smithy.GenericAPIError{Code: "AlreadyExistsException", Message: "ChangeSet " + aws.ToString(params.ChangeSetName) + " already exists"}
+	}
+	c.exists = true
+	return &cloudformation.CreateChangeSetOutput{}, nil
+}
+
+func (c *changeSetClient) DescribeChangeSet(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error) {
+	return &cloudformation.DescribeChangeSetOutput{Status: c.status, StatusReason: aws.String(c.statusReason)}, nil
+}
+
+func (c *changeSetClient) DeleteChangeSet(ctx context.Context, params *cloudformation.DeleteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error) {
+	c.exists = false
+	return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+func TestApplyRetainChangeSetRerun(t *testing.T) {
+	stackPollInterval = 0
+
+	tests := []struct {
+		name         string
+		statusReason string
+		wantErr      string
+	}{
+		{
+			name:         "change set without changes",
+			statusReason: "The submitted information didn't contain changes. Submit different information to create a change set.",
+		},
+		{
+			name:         "failed change set",
+			statusReason: "Resource types [AWS::EC2::Subnet] don't support UpdateReplacePolicy",
+			wantErr:      "don't support UpdateReplacePolicy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &changeSetClient{Client: fake.LoadFixture(t), status: cfn_types.ChangeSetStatusFailed, statusReason: tt.statusReason}
+			stackName := "networking-dedicated-spoke-dev"
+			// the second run finds the change set of the first one deleted
+			for run := 1; run <= 2; run++ {
+				err := applyRetainChangeSet(client, &stackName, "Resources: {}\n")
+				var apiErr smithy.APIError
+				if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AlreadyExistsException" {
+					t.Fatalf("run %d: applyRetainChangeSet() error = %v, the failed change set wasn't deleted", run, err)
+				}
+				if tt.wantErr == "" && err != nil {
+					t.Fatalf("run %d: applyRetainChangeSet() error = %v", run, err)
+				}
+				if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+					t.Fatalf("run %d: applyRetainChangeSet() error = %v, want %q", run, err, tt.wantErr)
+				}
+			}
+			if client.exists {
+				t.Error("applyRetainChangeSet() left the failed change set on the stack")
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package tf_import
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"vpc-import-cli/common"
+)
+
+// TerraformRollback removes from terraform state the addresses the manifest records as imported for the stack.
+// When the manifest has no entries at all for the stack the mapping is resolved against the stack instead, so a
+// run from before the manifest existed can still be undone. Addresses recorded as already_in_state weren't
+// imported by this tool and are never removed. Only addresses actually in state are removed, and confirm is
+// called with them before anything changes - with dryRun set they're printed and nothing is removed
+func TerraformRollback(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping Mapping,
+	stackName_p *string,
+	workingDir string,
+	dryRun bool,
+	confirm func(addresses []string) bool) error {
+
+	manifest, err := LoadManifest(filepath.Join(workingDir, ManifestFileName))
+	if err != nil {
+		return err
+	}
+	addresses, err := rollbackAddresses(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p, manifest)
+	if err != nil {
+		return err
+	}
+
+	tf, err := terraformInit(workingDir, importTerraformVersion)
+	if err != nil {
+		return err
+	}
+	stateAddresses, err := terraformStateAddresses(tf)
+	if err != nil {
+		return err
+	}
+	inState := []string{}
+	for _, address := range addresses {
+		if stateAddresses[address] {
+			inState = append(inState, address)
+		} else {
+			log.Printf("Skipping Resource Address: %s, not in terraform state", address)
+		}
+	}
+	if len(inState) == 0 {
+		log.Println("Nothing to roll back")
+		return nil
+	}
+
+	if dryRun {
+		for _, address := range inState {
+			fmt.Println(address)
+		}
+		fmt.Printf("\n%d addresses would be removed from terraform state\n", len(inState))
+		return nil
+	}
+	if !confirm(inState) {
+		return errors.New("rollback cancelled")
+	}
+
+	var rollbackErr error
+	for _, address := range inState {
+		log.Printf("Removing Resource Address: %s from terraform state", address)
+		if err := tf.StateRm(context.Background(), address); err != nil {
+			rollbackErr = errors.Join(rollbackErr, fmt.Errorf("removing %s from terraform state: %w", address, err))
+			continue
+		}
+		entry, _ := manifest.Entry(*stackName_p, address)
+		entry.Stack, entry.Address = *stackName_p, address
+		entry.Status, entry.Error = StatusRolledBack, ""
+		entry.Timestamp = time.Now().UTC()
+		manifest.Record(entry)
+	}
+	if err := manifest.Save(filepath.Join(workingDir, ManifestFileName)); err != nil {
+		rollbackErr = errors.Join(rollbackErr, fmt.Errorf("saving manifest: %w", err))
+	}
+	return rollbackErr
+}
+
+// rollbackAddresses returns the stack's addresses the manifest records as imported, or every address in the
+// mapping when the manifest has no entries for the stack, leaving out those recorded as already_in_state
+func rollbackAddresses(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping Mapping,
+	stackName_p *string,
+	manifest *Manifest) ([]string, error) {
+
+	addresses := []string{}
+	for _, entry := range manifest.EntriesForStack(*stackName_p, StatusImported) {
+		addresses = append(addresses, entry.Address)
+	}
+	if !manifest.HasStack(*stackName_p) {
+		log.Printf("%s has no entries for stack %s, rolling back every address in the mapping", ManifestFileName, *stackName_p)
+		importTargets, _, err := LoadImportTargets(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p)
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range importableTargets(importTargets) {
+			addresses = append(addresses, target.Address)
+		}
+	}
+	alreadyInState := map[string]bool{}
+	for _, entry := range manifest.EntriesForStack(*stackName_p, StatusAlreadyInState) {
+		alreadyInState[entry.Address] = true
+	}
+
+	rollback := []string{}
+	for _, address := range addresses {
+		if alreadyInState[address] {
+			log.Printf("Skipping Resource Address: %s, it was in terraform state before it was imported", address)
+			continue
+		}
+		rollback = append(rollback, address)
+	}
+	return rollback, nil
+}
@@ -0,0 +1,204 @@
+package tf_import
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// statuses of a StubAddress
+const (
+	StubMissing = "missing"
+	StubExtra   = "extra"
+	StubUnkeyed = "unkeyed"
+)
+
+// the instance keys of an address, ex. ["0"]
+var instanceKeysRegexp = regexp.MustCompile(`\[([^\]]*)\]`)
+
+// the resource blocks of a module file, and the arguments of a block that give it instance keys
+var (
+	moduleFileSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "resource", LabelNames: []string{"type", "name"}}},
+	}
+	resourceInstanceSchema = &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "count"}, {Name: "for_each"}},
+	}
+)
+
+// StubAddress is a resource the mapping and the module disagree on, Address is relative to the module.
+// Keys are the instance keys the mapping imports to, or an instance_key template, a missing stub for them is
+// written with for_each or count
+type StubAddress struct {
+	Address string
+	Status  string
+	Keys    []string
+}
+
+// CheckModuleStubs compares the addresses the mapping imports to with the resource blocks in the module
+// directory's .tf files. Mapped addresses without a block are missing, blocks the mapping doesn't import to are
+// extra, and mapped addresses with an instance key whose block has neither for_each nor count are unkeyed
+func CheckModuleStubs(mapping Mapping, moduleDir string) ([]StubAddress, error) {
+	blocks, err := moduleResourceBlocks(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+
+	mapped := map[string][]string{}
+	for _, resource := range mapping.Resources {
+		address := moduleRelativeAddress(resource.Address)
+		withoutKey := instanceKeyRegexp.ReplaceAllString(address, "")
+		keys := mapped[withoutKey]
+		for _, match := range instanceKeysRegexp.FindAllStringSubmatch(address, -1) {
+			keys = append(keys, match[1])
+		}
+		// a template is reported as written, it renders a different key per stack
+		if strings.Contains(resource.InstanceKey, "{{") {
+			keys = append(keys, resource.InstanceKey)
+		} else if resource.InstanceKey != "" {
+			keys = append(keys, fmt.Sprintf("%q", resource.InstanceKey))
+		} else if resource.ResourceType != "" {
+			keys = append(keys, resourceTypeIndexKey(resource.ResourceType))
+		}
+		mapped[withoutKey] = keys
+	}
+
+	stubs := []StubAddress{}
+	for address, keys := range mapped {
+		instanced, ok := blocks[address]
+		switch {
+		case !ok:
+			stubs = append(stubs, StubAddress{Address: address, Status: StubMissing, Keys: keys})
+		case len(keys) > 0 && !instanced:
+			stubs = append(stubs, StubAddress{Address: address, Status: StubUnkeyed, Keys: keys})
+		}
+	}
+	for address := range blocks {
+		if _, ok := mapped[address]; !ok {
+			stubs = append(stubs, StubAddress{Address: address, Status: StubExtra})
+		}
+	}
+	sort.Slice(stubs, func(i, j int) bool { return stubs[i].Address < stubs[j].Address })
+	return stubs, nil
+}
+
+// moduleResourceBlocks returns every resource block in the module's .tf files keyed by <type>.<name>, true
+// when the block has a count or for_each argument
+func moduleResourceBlocks(moduleDir string) (map[string]bool, error) {
+	paths, err := filepath.Glob(filepath.Join(moduleDir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .tf files in module %s", moduleDir)
+	}
+	parser := hclparse.NewParser()
+	blocks := map[string]bool{}
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("parsing %s: %w", path, diags)
+		}
+		content, _, diags := file.Body.PartialContent(moduleFileSchema)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("reading the resource blocks of %s: %w", path, diags)
+		}
+		for _, block := range content.Blocks {
+			arguments, _, diags := block.Body.PartialContent(resourceInstanceSchema)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("reading resource %s.%s in %s: %w", block.Labels[0], block.Labels[1], path, diags)
+			}
+			blocks[block.Labels[0]+"."+block.Labels[1]] = len(arguments.Attributes) > 0
+		}
+	}
+	return blocks, nil
+}
+
+// WriteMissingStubs appends an empty resource block to moduleDir/main.tf for each missing address, with
+// for_each over the string instance keys or count covering the number keys
+func WriteMissingStubs(moduleDir string, stubs []StubAddress) error {
+	path := filepath.Join(moduleDir, "main.tf")
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	log.Println("Writing missing stubs to Path: " + f.Name())
+	w := bufio.NewWriter(f)
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		fmt.Fprintln(w)
+	}
+	for _, stub := range stubs {
+		if stub.Status != StubMissing {
+			continue
+		}
+		parts := strings.SplitN(stub.Address, ".", 2)
+		if len(stub.Keys) == 0 {
+			fmt.Fprintf(w, "resource %q %q {}\n", parts[0], parts[1])
+			continue
+		}
+		fmt.Fprintf(w, "resource %q %q {\n  %s\n}\n", parts[0], parts[1], instanceArgument(stub.Keys))
+	}
+	return w.Flush()
+}
+
+// resourceTypeIndexKey is the key reported for an address indexed per stack resource of the type
+func resourceTypeIndexKey(resourceType string) string {
+	return "<index of " + resourceType + ">"
+}
+
+func instanceArgument(keys []string) string {
+	for _, key := range keys {
+		if strings.Contains(key, "{{") {
+			return "for_each = toset([]) # set to the keys instance_key renders: " + key
+		}
+		if resourceType, ok := strings.CutPrefix(key, "<index of "); ok {
+			return "count = 0 # set to the number of " + strings.TrimSuffix(resourceType, ">") + " resources in the stack"
+		}
+	}
+	if strings.HasPrefix(keys[0], `"`) {
+		return "for_each = toset([" + strings.Join(keys, ", ") + "])"
+	}
+	count := 0
+	for _, key := range keys {
+		var index int
+		fmt.Sscanf(key, "%d", &index)
+		if index+1 > count {
+			count = index + 1
+		}
+	}
+	return fmt.Sprintf("count = %d", count)
+}
+
+// PrintStubReport writes a table of the addresses the mapping and the module disagree on
+func PrintStubReport(out io.Writer, moduleDir string, stubs []StubAddress) {
+	if len(stubs) == 0 {
+		fmt.Fprintf(out, "%s has a resource block for every mapped address and no others\n", moduleDir)
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tADDRESS\tINSTANCE KEYS\t")
+	counts := map[string]int{}
+	for _, stub := range stubs {
+		counts[stub.Status]++
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", stub.Status, stub.Address, valueOrDash(strings.Join(stub.Keys, ", ")))
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d missing from %s, %d unkeyed (mapped with an instance key, but no for_each or count), %d not in the mapping\n",
+		counts[StubMissing], moduleDir, counts[StubUnkeyed], counts[StubExtra])
+}
@@ -1,15 +1,25 @@
 package tf_import
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfn_types "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2_types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
 	version "github.com/hashicorp/go-version"
 	"github.com/hashicorp/hc-install/fs"
 	"github.com/hashicorp/hc-install/product"
@@ -18,158 +28,432 @@ import (
 	"vpc-import-cli/common"
 )
 
-// mapping of cloudformation logical ids to terraform resource ids
-var logicalIdToTfResourceId = map[string]string{
-	"VPC":                         "module.vpc.aws_vpc.main",
-	"DhcpOptions":                 "module.vpc.aws_vpc_dhcp_options.main",
-	"DefaultNacl":                 "module.vpc.aws_default_network_acl.main",
-	"RouteTable":                  "module.vpc.aws_route_table.main",
-	"Route":                       "module.vpc.aws_route.main",
-	"SgBase":                      "module.vpc.aws_security_group.base",
-	"SgBaseEgress":                "module.vpc.aws_security_group_rule.base_egress",
-	"SgBaseIngressV4":             "module.vpc.aws_security_group_rule.base_ingress_v4",
-	"Subnet1":                     "module.vpc.aws_subnet.subnet_1",
-	"Subnet2":                     "module.vpc.aws_subnet.subnet_2",
-	"Subnet3":                     "module.vpc.aws_subnet.subnet_3",
-	"SubnetRouteAssociation1":     "module.vpc.aws_route_table_association.rt_association_1",
-	"SubnetRouteAssociation2":     "module.vpc.aws_route_table_association.rt_association_2",
-	"SubnetRouteAssociation3":     "module.vpc.aws_route_table_association.rt_association_3",
-	"TgwRoute":                    "module.vpc.aws_ec2_transit_gateway_route.main",
-	"TgwAttach":                   "module.vpc.aws_ec2_transit_gateway_vpc_attachment.main[\"0\"]",
-	"TgwRouteAssocation":          "module.vpc.aws_ec2_transit_gateway_route_table_association.main",
-	"TgwRoutePropagation":         "module.vpc.aws_ec2_transit_gateway_route_table_propagation.main",
-	"TgwMSKAttachmentPropagation": "module.vpc.aws_ec2_transit_gateway_route_table_propagation.msk",
-	"ResourceShare":               "module.vpc.aws_ram_resource_share.vpc",
-	"VpcDhcp":                     "module.vpc.aws_vpc_dhcp_options_association.main",
-	"VpcEndpointEC2":              "module.vpc.aws_vpc_endpoint.ec2",
-	"VpcEndpointEC2Messages":      "module.vpc.aws_vpc_endpoint.ec2messages",
-	"VpcEndpointS3":               "module.vpc.aws_vpc_endpoint.s3",
-	"VpcEndpointSSM":              "module.vpc.aws_vpc_endpoint.ssm",
-	"FlowLogsVpcEnable":           "module.vpc.aws_flow_log.main",
-}
-
-func TerraformImport(cfn_client_p *cloudformation.Client,
-	ec2_client_p *ec2.Client,
-	route53resolver_client_p *route53resolver.Client,
-	stackName_p *string) {
-
-	stackResourcesOutput_p := common.GetStackResourcesOutput(cfn_client_p, stackName_p)
-	stacksOutput_p := common.GetStacksOutput(cfn_client_p, stackName_p)
-
-	tfResourceIdsToPhysicalIds := mapTfResourceIdsToPhysicalIds(ec2_client_p,
-		route53resolver_client_p,
-		*stacksOutput_p,
-		*stackResourcesOutput_p)
+// terraform versions used for each import mode - import blocks require terraform 1.5+
+const (
+	importTerraformVersion       = "1.4.6"
+	importBlocksTerraformVersion = "1.5.7"
+	importBlocksFileName         = "imports.tf"
+	importBlocksPlanFileName     = "imports.tfplan"
+)
 
-	tf := terraformInit()
+// ImportTarget is a single resource to import: where it comes from in the cloudformation stack
+// and where it lands in terraform
+type ImportTarget struct {
+	LogicalId  string
+	PhysicalId string
+	ImportId   string
+	Address    string
+	// EmptyInputs are the import_id template inputs that resolved empty, ex. a missing physicalId, the import id
+	// composed from them is incomplete so the target isn't imported
+	EmptyInputs []string
+}
 
-	for tfResourceId, physicalId := range tfResourceIdsToPhysicalIds {
-		log.Printf("Importing PhysicalId: %s to Resource Address: %s", physicalId, tfResourceId)
-		err := tf.Import(context.Background(), tfResourceId, physicalId)
-		common.Check(err)
-	}
+// ImportResult is the outcome of importing a single target, Err is nil when the import succeeded.
+// Skipped is set for targets --resume found already imported
+type ImportResult struct {
+	Target  ImportTarget
+	Err     error
+	Skipped bool
 }
 
-func mapTfResourceIdsToPhysicalIds(ec2_client_p *ec2.Client,
-	route53resolver_client_p *route53resolver.Client,
-	stacksOutput_p cloudformation.DescribeStacksOutput,
-	stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput) map[string]string {
+// TerraformImport imports each target one at a time, carrying on past failures so a single bad resource
+// doesn't leave the rest unimported. The returned error joins a *common.ErrImportFailed per failed address.
+// Each result is saved to the manifest as soon as it's known, with resume set targets the manifest
+// or terraform state already has are skipped
+func TerraformImport(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping Mapping,
+	stackName_p *string,
+	workingDir string,
+	resume bool) ([]ImportResult, error) {
+
+	if err := checkTfVarsSubnets(workingDir); err != nil {
+		return nil, err
+	}
+	importTargets, _, err := LoadImportTargets(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := LoadManifest(filepath.Join(workingDir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	tf, err := terraformInit(workingDir, importTerraformVersion)
+	if err != nil {
+		return nil, err
+	}
 
-	vpc_ip_range := common.GetParameterValue(stacksOutput_p, "IpRange")
-	tgw_route_table_id := common.GetParameterResolvedValue(stacksOutput_p, "TgwRouteTableID")
-	msk_tgw_route_table_id := common.GetParameterResolvedValue(stacksOutput_p, "TgwMSKRouteTableID")
+	results := []ImportResult{}
+	importTargets = importableTargets(importTargets)
+	if resume {
+		var skippedTargets []ImportTarget
+		if importTargets, skippedTargets, err = resumeTargets(tf, manifest, *stackName_p, importTargets); err != nil {
+			return nil, err
+		}
+		results = append(results, skippedResults(skippedTargets)...)
+	}
 
-	logicalIdsToPhysicalIds := map[string]string{}
+	var importErr error
+	for _, target := range importTargets {
+		log.Printf("Importing PhysicalId: %s to Resource Address: %s", target.ImportId, target.Address)
+		result := ImportResult{Target: target}
+		if err := tf.Import(context.Background(), target.Address, target.ImportId); err != nil {
+			result.Err = &common.ErrImportFailed{Address: target.Address, ImportId: target.ImportId, Err: err}
+			importErr = errors.Join(importErr, result.Err)
+			log.Println(result.Err)
+		}
+		results = append(results, result)
 
-	for _, resource := range stackResourcesOutput_p.StackResources {
-		physicalResourceId := *resource.PhysicalResourceId
-		logicalResourceId := *resource.LogicalResourceId
-		if logicalIdToTfResourceId[logicalResourceId] != "" {
-			logicalIdsToPhysicalIds[logicalResourceId] = physicalResourceId
+		manifest.Record(newManifestEntry(*stackName_p, result))
+		if err := manifest.Save(filepath.Join(workingDir, ManifestFileName)); err != nil {
+			return results, errors.Join(importErr, fmt.Errorf("saving manifest: %w", err))
 		}
 	}
+	return results, importErr
+}
 
-	// update these physical ids to match format required by terraform import
-	logicalIdsToPhysicalIds["SubnetRouteAssociation1"] = logicalIdsToPhysicalIds["Subnet1"] + "/" + logicalIdsToPhysicalIds["RouteTable"]
-	logicalIdsToPhysicalIds["SubnetRouteAssociation2"] = logicalIdsToPhysicalIds["Subnet2"] + "/" + logicalIdsToPhysicalIds["RouteTable"]
-	logicalIdsToPhysicalIds["SubnetRouteAssociation3"] = logicalIdsToPhysicalIds["Subnet3"] + "/" + logicalIdsToPhysicalIds["RouteTable"]
+// TerraformImportBlocks writes the same mapping used by TerraformImport to an imports.tf file of
+// terraform import blocks, then imports the whole stack with a single terraform plan/apply.
+// The plan is printed and confirm asked before it's applied. The apply is atomic so every target shares the
+// same result. imports.tf and the plan are removed before returning, terraform 1.4 used by the other commands
+// can't parse them. When resume leaves nothing to import no plan is run
+func TerraformImportBlocks(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping Mapping,
+	stackName_p *string,
+	workingDir string,
+	resume bool,
+	confirm func(step string) bool) ([]ImportResult, error) {
+
+	if err := checkTfVarsSubnets(workingDir); err != nil {
+		return nil, err
+	}
+	importTargets, _, err := LoadImportTargets(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := LoadManifest(filepath.Join(workingDir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
 
-	// update security group rule ids to match format required by terraform import
-	ingressId, egressId := getSecurityGroupRulePhysicalIds(ec2_client_p, logicalIdsToPhysicalIds["SgBase"])
-	logicalIdsToPhysicalIds["SgBaseIngressV4"] = ingressId
-	logicalIdsToPhysicalIds["SgBaseEgress"] = egressId
+	tf, err := terraformInit(workingDir, importBlocksTerraformVersion)
+	if err != nil {
+		return nil, err
+	}
 
-	// update dhcp options id with that associated with the vpc rather than that defined in the stack (in case they are not the same)
-	logicalIdsToPhysicalIds["DhcpOptions"] = common.GetDhcpOptionsIdFromVpc(ec2_client_p, logicalIdsToPhysicalIds["VPC"])
-	logicalIdsToPhysicalIds["DefaultNacl"] = getDefaultNaclIdFromVpc(ec2_client_p, logicalIdsToPhysicalIds["VPC"])
+	results := []ImportResult{}
+	importTargets = importableTargets(importTargets)
+	if resume {
+		var skippedTargets []ImportTarget
+		if importTargets, skippedTargets, err = resumeTargets(tf, manifest, *stackName_p, importTargets); err != nil {
+			return nil, err
+		}
+		results = append(results, skippedResults(skippedTargets)...)
+	}
 
-	// update dhcp options association id with VPC id to match requirement for terraform import
-	logicalIdsToPhysicalIds["VpcDhcp"] = logicalIdsToPhysicalIds["VPC"]
+	if len(importTargets) == 0 {
+		log.Println("No addresses left to import, skipping terraform plan")
+		return results, nil
+	}
 
-	// update default Route physical id to match requirement for terraform import
-	logicalIdsToPhysicalIds["Route"] = logicalIdsToPhysicalIds["RouteTable"] + "_0.0.0.0/0"
+	// written once resume has dropped the addresses already in state, an import block for one would fail the plan
+	if err = writeImportBlocksToFile(workingDir, importTargets); err != nil {
+		return nil, err
+	}
+	defer removeImportBlocksFiles(workingDir)
 
-	// update TgwRoute physical id to match requirement for terraform import
-	logicalIdsToPhysicalIds["TgwRoute"] = tgw_route_table_id + "_" + vpc_ip_range
+	log.Println("Running terraform plan...")
+	if _, err = tf.Plan(context.Background(), tfexec.Out(importBlocksPlanFileName)); err != nil {
+		return nil, fmt.Errorf("running terraform plan: %w", err)
+	}
+	plan, err := tf.ShowPlanFileRaw(context.Background(), importBlocksPlanFileName)
+	if err != nil {
+		return nil, fmt.Errorf("showing terraform plan: %w", err)
+	}
+	fmt.Println(plan)
+	if !confirm(fmt.Sprintf("Apply the plan, importing %d addresses", len(importTargets))) {
+		return nil, errors.New("import cancelled")
+	}
 
-	// update TgwRouteAssocation physical id to match requirement for terraform import
-	// see https://registry.terraform.io/providers/hashicorp/aws/latest/docs/resources/ec2_transit_gateway_route_table_association#import
-	logicalIdsToPhysicalIds["TgwRouteAssocation"] = tgw_route_table_id + "_" + logicalIdsToPhysicalIds["TgwAttach"]
+	log.Println("Running terraform apply...")
+	applyErr := tf.Apply(context.Background(), tfexec.DirOrPlan(importBlocksPlanFileName))
 
-	// update TgwRoutePropagation physical id to match requirement for terraform import
-	// yes, it has the exact same calculated id as TgwRouteAssocation, see
-	// https://registry.terraform.io/providers/hashicorp/aws/latest/docs/resources/ec2_transit_gateway_route_table_propagation#import
-	logicalIdsToPhysicalIds["TgwRoutePropagation"] = tgw_route_table_id + "_" + logicalIdsToPhysicalIds["TgwAttach"]
+	var importErr error
+	for _, target := range importTargets {
+		result := ImportResult{Target: target}
+		if applyErr != nil {
+			result.Err = &common.ErrImportFailed{Address: target.Address, ImportId: target.ImportId, Err: applyErr}
+			importErr = errors.Join(importErr, result.Err)
+		}
+		results = append(results, result)
+		manifest.Record(newManifestEntry(*stackName_p, result))
+	}
+	if err := manifest.Save(filepath.Join(workingDir, ManifestFileName)); err != nil {
+		return results, errors.Join(importErr, fmt.Errorf("saving manifest: %w", err))
+	}
+	return results, importErr
+}
 
-	// update TgwMSKAttachmentPropagation physical id to match requirement for terraform import
-	logicalIdsToPhysicalIds["TgwMSKAttachmentPropagation"] = msk_tgw_route_table_id + "_" + logicalIdsToPhysicalIds["TgwAttach"]
+// checkTfVarsSubnets fails when the working directory's tfvars don't set subnets, ex. written by --genvars before
+// subnets were mapped by resource type. The variable has no default, the subnets are imported at its indexes
+func checkTfVarsSubnets(workingDir string) error {
+	path := filepath.Join(workingDir, tfvarsFileName)
+	tfvarsJson, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s not found, run --genvars to write it before importing", path)
+	}
+	if err != nil {
+		return err
+	}
+	tfvars := map[string]json.RawMessage{}
+	if err = json.Unmarshal(tfvarsJson, &tfvars); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if _, ok := tfvars["subnets"]; !ok {
+		return fmt.Errorf("%s doesn't set subnets, run --genvars to rewrite it before importing", path)
+	}
+	return nil
+}
 
-	tfResourceIdsToPhysicalIds := map[string]string{}
+func skippedResults(importTargets []ImportTarget) []ImportResult {
+	results := []ImportResult{}
+	for _, target := range importTargets {
+		log.Printf("Skipping Resource Address: %s, already imported", target.Address)
+		results = append(results, ImportResult{Target: target, Skipped: true})
+	}
+	return results
+}
 
-	for logicalId, tfResourceId := range logicalIdToTfResourceId {
-		// this logic will remove flow logs (and other resources) from list of resources to import if physical id doesn't exist
-		// (in cloudformation stack flow log resource is only created in main org)
-		// vpc module will create new flow log resource instead
-		if logicalIdsToPhysicalIds[logicalId] != "" {
-			tfResourceIdsToPhysicalIds[tfResourceId] = logicalIdsToPhysicalIds[logicalId]
+// TerraformImportDryRun prints what TerraformImport would do without initializing terraform or touching state
+func TerraformImportDryRun(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping Mapping,
+	stackName_p *string) error {
+
+	importTargets, ignoredResources, err := LoadImportTargets(cfn_client_p, ec2_client_p, route53resolver_client_p, mapping, stackName_p)
+	if err != nil {
+		return err
+	}
+
+	printImportTargets(os.Stdout, importTargets, ignoredResources)
+	return nil
+}
+
+// PrintImportSummary writes a per address success/failure table for the results of an import
+func PrintImportSummary(out io.Writer, results []ImportResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tADDRESS\tIMPORT ID\tERROR\t")
+	failedCount := 0
+	skippedCount := 0
+	for _, result := range results {
+		status := "imported"
+		errMsg := "-"
+		if result.Err != nil {
+			status = "FAILED"
+			// only the first line, terraform errors span many
+			errMsg = strings.SplitN(errors.Unwrap(result.Err).Error(), "\n", 2)[0]
+			failedCount++
+		} else if result.Skipped {
+			status = "skipped"
+			skippedCount++
 		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", status, result.Target.Address, result.Target.ImportId, errMsg)
 	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d imported, %d skipped, %d failed\n", len(results)-failedCount-skippedCount, skippedCount, failedCount)
+}
 
-	// get resolver rule association ids and add them to mapping to be imported - these association resources are not included
-	// in the cloudformation stack but are necessary for the dns functionality of the vpc, and will be managed as part of the
-	// vpc terraform module
+// LoadImportTargets describes the stack and returns every address in the mapping along with the stack
+// resources the mapping doesn't cover
+func LoadImportTargets(cfn_client_p common.StackDescriber,
+	ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping Mapping,
+	stackName_p *string) ([]ImportTarget, []cfn_types.StackResource, error) {
 
-	internetAssoc := common.GetResolverRuleAssociation(route53resolver_client_p, logicalIdsToPhysicalIds["VPC"], common.ResolverRuleInternet)
+	stackResourcesOutput_p, err := common.GetStackResourcesOutput(cfn_client_p, stackName_p)
+	if err != nil {
+		return nil, nil, err
+	}
+	stacksOutput_p, err := common.GetStacksOutput(cfn_client_p, stackName_p)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	tldAssoc := common.GetResolverRuleAssociation(route53resolver_client_p, logicalIdsToPhysicalIds["VPC"], common.ResolverRuleTldIac)
+	return getImportTargets(ec2_client_p,
+		route53resolver_client_p,
+		mapping,
+		*stacksOutput_p,
+		*stackResourcesOutput_p)
+}
 
-	if tldAssoc == nil {
-		tldAssoc = common.GetResolverRuleAssociation(route53resolver_client_p, logicalIdsToPhysicalIds["VPC"], common.ResolverRuleTldLegacy)
+func printImportTargets(out io.Writer, importTargets []ImportTarget, ignoredResources []cfn_types.StackResource) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LOGICAL ID\tPHYSICAL ID\tIMPORT ID\tADDRESS\t")
+	emptyCount := 0
+	incompleteCount := 0
+	for _, target := range importTargets {
+		importId := target.ImportId
+		if importId == "" {
+			importId = "<EMPTY - WILL NOT BE IMPORTED>"
+			emptyCount++
+		} else if len(target.EmptyInputs) > 0 {
+			importId = fmt.Sprintf("<INCOMPLETE %q, EMPTY %s - WILL NOT BE IMPORTED>", importId, strings.Join(target.EmptyInputs, ", "))
+			incompleteCount++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", valueOrDash(target.LogicalId), valueOrDash(target.PhysicalId), importId, target.Address)
 	}
+	w.Flush()
 
-	crossVpcAssoc := common.GetResolverRuleAssociation(route53resolver_client_p, logicalIdsToPhysicalIds["VPC"], common.ResolverRuleCrossVpcIac)
+	fmt.Fprintf(out, "\n%d addresses mapped, %d with an empty import id, %d with an incomplete import id\n", len(importTargets), emptyCount, incompleteCount)
+
+	if len(ignoredResources) > 0 {
+		fmt.Fprintf(out, "\nStack resources not mapped to a terraform address (%d):\n", len(ignoredResources))
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "LOGICAL ID\tPHYSICAL ID\tRESOURCE TYPE\t")
+		for _, resource := range ignoredResources {
+			fmt.Fprintf(w, "%s\t%s\t%s\t\n", *resource.LogicalResourceId, valueOrDash(aws.ToString(resource.PhysicalResourceId)), *resource.ResourceType)
+		}
+		w.Flush()
+	}
+}
 
-	if crossVpcAssoc == nil {
-		crossVpcAssoc = common.GetResolverRuleAssociation(route53resolver_client_p, logicalIdsToPhysicalIds["VPC"], common.ResolverRuleCrossVpcLegacy)
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
 	}
+	return value
+}
 
-	if internetAssoc != nil {
-		tfResourceIdsToPhysicalIds["module.vpc.aws_route53_resolver_rule_association.internet"] = *internetAssoc.Id
+// importableTargets drops targets whose import id came back empty
+// (in cloudformation stack flow log resource is only created in main org - vpc module will create new flow log resource instead)
+// or was composed from an empty input
+func importableTargets(importTargets []ImportTarget) []ImportTarget {
+	f := func(target ImportTarget) bool {
+		if target.ImportId != "" && len(target.EmptyInputs) > 0 {
+			log.Printf("Skipping Resource Address: %s, import id %q is missing %s", target.Address, target.ImportId, strings.Join(target.EmptyInputs, ", "))
+			return false
+		}
+		return target.ImportId != ""
 	}
+	return common.Filter(importTargets, f)
+}
 
-	if tldAssoc != nil {
-		tfResourceIdsToPhysicalIds["module.vpc.aws_route53_resolver_rule_association.mskcc_tld"] = *tldAssoc.Id
+func writeImportBlocksToFile(workingDir string, importTargets []ImportTarget) error {
+	f, err := os.Create(filepath.Join(workingDir, importBlocksFileName)) // Note: This operation truncates an existing file
+	if err != nil {
+		return err
 	}
+	defer f.Close()
 
-	if crossVpcAssoc != nil {
-		tfResourceIdsToPhysicalIds["module.vpc.aws_route53_resolver_rule_association.cross_vpc"] = *crossVpcAssoc.Id
+	log.Println("Writing import blocks to Path: " + f.Name())
+	w := bufio.NewWriter(f)
+	for _, target := range importTargets {
+		fmt.Fprintf(w, "import {\n  to = %s\n  id = %q\n}\n\n", target.Address, target.ImportId)
 	}
+	return w.Flush()
+}
 
-	return tfResourceIdsToPhysicalIds
+// removeImportBlocksFiles removes imports.tf and its saved plan once the import blocks are applied or abandoned,
+// a failure is only logged since the import itself is already done
+func removeImportBlocksFiles(workingDir string) {
+	for _, fileName := range []string{importBlocksFileName, importBlocksPlanFileName} {
+		path := filepath.Join(workingDir, fileName)
+		log.Println("Removing import blocks file at Path: " + path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("removing %s, delete it before running terraform 1.4: %s", path, err)
+		}
+	}
 }
 
-func terraformInit() *tfexec.Terraform {
-	var required_version = "1.4.6"
+// getImportTargets returns every terraform address in the mapping, sorted by address, along with the
+// stack resources the mapping doesn't cover
+func getImportTargets(ec2_client_p common.VpcDescriber,
+	route53resolver_client_p common.ResolverRuleLister,
+	mapping Mapping,
+	stacksOutput_p cloudformation.DescribeStacksOutput,
+	stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput) ([]ImportTarget, []cfn_types.StackResource, error) {
+
+	logicalIdsToPhysicalIds := map[string]string{}
+	ignoredResources := []cfn_types.StackResource{}
+
+	for _, resource := range stackResourcesOutput_p.StackResources {
+		logicalResourceId := *resource.LogicalResourceId
+		logicalIdsToPhysicalIds[logicalResourceId] = aws.ToString(resource.PhysicalResourceId)
+		if !mapping.mapsResource(logicalResourceId, aws.ToString(resource.ResourceType)) {
+			ignoredResources = append(ignoredResources, resource)
+		}
+	}
+
+	importTargets := []ImportTarget{}
+
+	stackName := aws.ToString(stacksOutput_p.Stacks[0].StackName)
+	stackResourceMappings, err := mapping.stackResources(stackResourcesOutput_p, ec2_client_p)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, stackResourceMapping := range stackResourceMappings {
+		resource := stackResourceMapping.ResourceMapping
+		target := ImportTarget{
+			LogicalId:  resource.LogicalId,
+			PhysicalId: logicalIdsToPhysicalIds[resource.LogicalId],
+		}
+		target.Address, err = mapping.stackAddress(resource, addressTemplateData{
+			StackName:  stackName,
+			LogicalId:  target.LogicalId,
+			PhysicalId: target.PhysicalId,
+			Index:      stackResourceMapping.Index,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		// a logical id missing from the stack leaves the import id empty so the resource is skipped
+		// (in cloudformation stack flow log resource is only created in main org)
+		stackResource, inStack := findStackResource(stackResourcesOutput_p, resource.LogicalId)
+		if resource.ImportId != "" && (inStack || resource.LogicalId == "") {
+			funcs := importIdFuncs(ec2_client_p, route53resolver_client_p, stacksOutput_p, logicalIdsToPhysicalIds, &target.EmptyInputs)
+			target.ImportId, err = renderImportId(resource, funcs, logicalIdsToPhysicalIds)
+		} else if inStack {
+			target.ImportId, err = resolveImportId(target.Address, ResolverInput{
+				Resource:              stackResource,
+				StacksOutput:          stacksOutput_p,
+				StackResourcesOutput:  stackResourcesOutput_p,
+				Ec2Client:             ec2_client_p,
+				Route53ResolverClient: route53resolver_client_p,
+			})
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("computing import id for %s: %w", target.Address, err)
+		}
+		// resources outside the stack are identified by their import id alone
+		if resource.LogicalId == "" {
+			target.PhysicalId = target.ImportId
+		}
+		importTargets = append(importTargets, target)
+	}
+
+	sort.Slice(importTargets, func(i, j int) bool {
+		return importTargets[i].Address < importTargets[j].Address
+	})
+
+	return importTargets, ignoredResources, nil
+}
+
+func findStackResource(stackResourcesOutput_p cloudformation.DescribeStackResourcesOutput, logicalId string) (cfn_types.StackResource, bool) {
+	for _, resource := range stackResourcesOutput_p.StackResources {
+		if *resource.LogicalResourceId == logicalId {
+			return resource, true
+		}
+	}
+	return cfn_types.StackResource{}, false
+}
+
+func terraformInit(workingDir string, required_version string) (*tfexec.Terraform, error) {
 	fsTfVersion := &fs.ExactVersion{
 		Product: product.Terraform,
 		Version: version.Must(version.NewVersion(required_version)),
@@ -178,29 +462,30 @@ func terraformInit() *tfexec.Terraform {
 	log.Println("Finding existing terraform install for version: " + required_version)
 	execPath, err := fsTfVersion.Find(context.Background())
 	if err != nil {
-		log.Fatalf("error finding Terraform: %s", err)
+		return nil, fmt.Errorf("error finding Terraform: %w", err)
 	}
 
-	workingDir := "."
 	tf, err := tfexec.NewTerraform(workingDir, execPath)
 	if err != nil {
-		log.Fatalf("error running NewTerraform: %s", err)
+		return nil, fmt.Errorf("error running NewTerraform: %w", err)
 	}
 
 	log.Println("Running terraform init...")
 	err = tf.Init(context.Background(), tfexec.Upgrade(true))
 	if err != nil {
-		log.Fatalf("error running Init: %s", err)
+		return nil, fmt.Errorf("error running Init: %w", err)
 	}
 
-	return tf
+	return tf, nil
 }
 
-func getSecurityGroupRulePhysicalIds(ec2_client_p *ec2.Client, physicalResourceId string) (string, string) {
+func getSecurityGroupRulePhysicalIds(ec2_client_p common.VpcDescriber, physicalResourceId string) (string, string, error) {
 	filterName := "group-id"
 	input := ec2.DescribeSecurityGroupRulesInput{Filters: []ec2_types.Filter{{Name: &filterName, Values: []string{physicalResourceId}}}}
 	output, err := ec2_client_p.DescribeSecurityGroupRules(context.TODO(), &input)
-	common.Check(err)
+	if err != nil {
+		return "", "", fmt.Errorf("describing rules of security group %s: %w", physicalResourceId, err)
+	}
 	ingressId := ""
 	egressId := ""
 	for _, sg := range output.SecurityGroupRules {
@@ -210,10 +495,10 @@ func getSecurityGroupRulePhysicalIds(ec2_client_p *ec2.Client, physicalResourceI
 			egressId = formatSecurityGroupRuleId(sg, "egress")
 		}
 	}
-	return ingressId, egressId
+	return ingressId, egressId, nil
 }
 
-func getDefaultNaclIdFromVpc(ec2_client_p *ec2.Client, physicalResourceId string) string {
+func getDefaultNaclIdFromVpc(ec2_client_p common.VpcDescriber, physicalResourceId string) (string, error) {
 	vpcIdStr := "vpc-id"
 	defaultStr := "default"
 	filters := []ec2_types.Filter{
@@ -228,8 +513,13 @@ func getDefaultNaclIdFromVpc(ec2_client_p *ec2.Client, physicalResourceId string
 	}
 	input := ec2.DescribeNetworkAclsInput{Filters: filters}
 	output, err := ec2_client_p.DescribeNetworkAcls(context.TODO(), &input)
-	common.Check(err)
-	return *output.NetworkAcls[0].NetworkAclId
+	if err != nil {
+		return "", fmt.Errorf("describing default network acl of vpc %s: %w", physicalResourceId, err)
+	}
+	if len(output.NetworkAcls) == 0 {
+		return "", fmt.Errorf("%w: default network acl of vpc %s", common.ErrResourceNotFound, physicalResourceId)
+	}
+	return *output.NetworkAcls[0].NetworkAclId, nil
 }
 
 func formatSecurityGroupRuleId(sg ec2_types.SecurityGroupRule, sg_rule_type string) string {
@@ -251,5 +541,5 @@ func formatSecurityGroupRuleId(sg ec2_types.SecurityGroupRule, sg_rule_type stri
 	} else {
 		toPort = strconv.Itoa(int(*sg.ToPort))
 	}
-	return fmt.Sprintf("%s_%s_%s_%s_%s_%s", *sg.GroupId, sg_rule_type, protocol, fromPort, toPort, *sg.CidrIpv4)
+	return composeAwsSecurityGroupRuleImportId(*sg.GroupId, sg_rule_type, protocol, fromPort, toPort, *sg.CidrIpv4)
 }
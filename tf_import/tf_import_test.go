@@ -0,0 +1,187 @@
+package tf_import
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"vpc-import-cli/common"
+	"vpc-import-cli/fake"
+)
+
+// loadImportIds reads the import id of every address in the recorded stack
+func loadImportIds(t *testing.T) map[string]string {
+	t.Helper()
+	importIdsJson, err := os.ReadFile("testdata/networking-dedicated-spoke-dev.import-ids.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	importIds := map[string]string{}
+	if err = json.Unmarshal(importIdsJson, &importIds); err != nil {
+		t.Fatal(err)
+	}
+	return importIds
+}
+
+func TestLoadImportTargets(t *testing.T) {
+	tgwRouteTableEmpty := []string{`liveParam "TgwRouteTableID"`, "{transit_gateway_route_table_id}"}
+
+	tests := []struct {
+		name          string
+		stackName     string
+		moduleAddress string
+		replacements  []string
+		// want edits the import ids and empty inputs of the recorded stack into the expected ones
+		want    func(importIds map[string]string, emptyInputs map[string][]string)
+		wantErr error
+	}{
+		{
+			name:      "recorded stack",
+			stackName: "networking-dedicated-spoke-dev",
+		},
+		{
+			name:          "module address",
+			stackName:     "networking-dedicated-spoke-dev",
+			moduleAddress: `module.spoke["{{.StackName}}"]`,
+			want: func(importIds map[string]string, emptyInputs map[string][]string) {
+				for address, importId := range importIds {
+					delete(importIds, address)
+					importIds[strings.Replace(address, "module.vpc.", `module.spoke["networking-dedicated-spoke-dev"].`, 1)] = importId
+				}
+			},
+		},
+		{
+			name:      "stale parameters take the live values",
+			stackName: "networking-dedicated-spoke-dev",
+			replacements: []string{
+				`"ResolvedValue": "tgw-rtb-0a1b2c3d4e5f60001"`,
+				`"ResolvedValue": "tgw-rtb-0a1b2c3d4e5f6ffff"`,
+				`"ParameterKey": "IpRange",
+                "ParameterValue": "10.20.0.0/22"`,
+				`"ParameterKey": "IpRange",
+                "ParameterValue": "10.30.0.0/22"`,
+			},
+		},
+		{
+			name:      "unassociated attachment and empty parameter leave the composed import ids incomplete",
+			stackName: "networking-dedicated-spoke-dev",
+			replacements: []string{
+				`"ResolvedValue": "tgw-rtb-0a1b2c3d4e5f60001"`,
+				`"ResolvedValue": ""`,
+				`"State": "associated"`,
+				`"State": "disassociated"`,
+			},
+			want: func(importIds map[string]string, emptyInputs map[string][]string) {
+				importIds["module.vpc.aws_ec2_transit_gateway_route.main"] = "_10.20.0.0/22"
+				for _, address := range []string{
+					"module.vpc.aws_ec2_transit_gateway_route_table_association.main",
+					"module.vpc.aws_ec2_transit_gateway_route_table_propagation.main",
+				} {
+					importIds[address] = "_tgw-attach-0a1b2c3d4e5f60001"
+					emptyInputs[address] = tgwRouteTableEmpty
+				}
+				emptyInputs["module.vpc.aws_ec2_transit_gateway_route.main"] = tgwRouteTableEmpty
+			},
+		},
+		{
+			name:      "route table association of a subnet outside the stack",
+			stackName: "networking-dedicated-spoke-dev",
+			replacements: []string{
+				`"RouteTableId": "rtb-0a1b2c3d4e5f60001",
+                "SubnetId": "subnet-0a1b2c3d4e5f60003"`,
+				`"RouteTableId": "rtb-0a1b2c3d4e5f60001",
+                "SubnetId": "subnet-0a1b2c3d4e5f6ffff"`,
+			},
+			wantErr: common.ErrResourceNotFound,
+		},
+		{
+			name:      "stack not found",
+			stackName: "does-not-exist",
+			wantErr:   common.ErrStackNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.LoadFixture(t, tt.replacements...)
+			mapping, err := LoadMapping("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			mapping.ModuleAddress = tt.moduleAddress
+			stackName := tt.stackName
+			importTargets, ignoredResources, err := LoadImportTargets(client, client, client, mapping, &stackName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LoadImportTargets() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			wantImportIds, wantEmptyInputs := loadImportIds(t), map[string][]string{}
+			if tt.want != nil {
+				tt.want(wantImportIds, wantEmptyInputs)
+			}
+			importIds, emptyInputs := map[string]string{}, map[string][]string{}
+			for _, target := range importTargets {
+				importIds[target.Address] = target.ImportId
+				if len(target.EmptyInputs) > 0 {
+					emptyInputs[target.Address] = target.EmptyInputs
+				}
+			}
+			if !reflect.DeepEqual(importIds, wantImportIds) {
+				t.Errorf("LoadImportTargets() import ids = %v, want %v", importIds, wantImportIds)
+			}
+			if !reflect.DeepEqual(emptyInputs, wantEmptyInputs) {
+				t.Errorf("LoadImportTargets() empty inputs = %v, want %v", emptyInputs, wantEmptyInputs)
+			}
+			if len(ignoredResources) != 1 || aws.ToString(ignoredResources[0].LogicalResourceId) != "SsmParameterVpcId" {
+				t.Errorf("LoadImportTargets() ignored resources = %v, want SsmParameterVpcId", ignoredResources)
+			}
+		})
+	}
+}
+
+func TestCheckTfVarsSubnets(t *testing.T) {
+	tests := []struct {
+		name    string
+		tfvars  string
+		wantErr string
+	}{
+		{
+			name:   "subnets set",
+			tfvars: `{"IpRange": "10.20.0.0/22", "subnets": []}`,
+		},
+		{
+			name:    "written before subnets were mapped",
+			tfvars:  `{"IpRange": "10.20.0.0/22"}`,
+			wantErr: "doesn't set subnets",
+		},
+		{
+			name:    "not written",
+			wantErr: "not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workingDir := t.TempDir()
+			if tt.tfvars != "" {
+				if err := os.WriteFile(filepath.Join(workingDir, tfvarsFileName), []byte(tt.tfvars), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := checkTfVarsSubnets(workingDir)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("checkTfVarsSubnets() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("checkTfVarsSubnets() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
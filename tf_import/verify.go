@@ -0,0 +1,174 @@
+package tf_import
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+
+	"vpc-import-cli/common"
+)
+
+const (
+	tfvarsFileName     = "terraform.tfvars.json"
+	verifyPlanFileName = "verify.tfplan"
+)
+
+// replacing or destroying any of these takes the spoke's network down, so verify fails on them
+var criticalResourceTypes = map[string]bool{
+	"aws_vpc":                                true,
+	"aws_subnet":                             true,
+	"aws_ec2_transit_gateway_vpc_attachment": true,
+}
+
+// PlannedChange is a resource a post-import plan would change, Attributes are the top level attributes that differ
+type PlannedChange struct {
+	Address    string
+	Type       string
+	Action     string
+	Attributes []string
+}
+
+// Critical is true when the change replaces or destroys a vpc, subnet or transit gateway attachment
+func (change PlannedChange) Critical() bool {
+	return criticalResourceTypes[change.Type] && (change.Action == "replace" || change.Action == "destroy")
+}
+
+// TerraformVerify plans the working directory against the generated tfvars and returns every resource the plan
+// would create, update, replace or destroy. The error wraps common.ErrCriticalReplacement when a critical
+// resource would be replaced or destroyed
+func TerraformVerify(workingDir string) ([]PlannedChange, error) {
+	if _, err := os.Stat(filepath.Join(workingDir, tfvarsFileName)); err != nil {
+		return nil, fmt.Errorf("%s is needed to verify the import, run --genvars first: %w", tfvarsFileName, err)
+	}
+
+	tf, err := terraformInit(workingDir, importBlocksTerraformVersion)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := terraformPlan(tf)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := plannedChanges(plan)
+	var verifyErr error
+	for _, change := range changes {
+		if change.Critical() {
+			verifyErr = errors.Join(verifyErr, fmt.Errorf("%w: %s %s", common.ErrCriticalReplacement, change.Action, change.Address))
+		}
+	}
+	return changes, verifyErr
+}
+
+// terraformPlan plans against the generated tfvars to a plan file and returns the parsed plan
+func terraformPlan(tf *tfexec.Terraform) (*tfjson.Plan, error) {
+	if _, err := tf.Plan(context.Background(), tfexec.VarFile(tfvarsFileName), tfexec.Out(verifyPlanFileName)); err != nil {
+		return nil, fmt.Errorf("running terraform plan: %w", err)
+	}
+	plan, err := tf.ShowPlanFile(context.Background(), verifyPlanFileName)
+	if err != nil {
+		return nil, fmt.Errorf("showing terraform plan: %w", err)
+	}
+	return plan, nil
+}
+
+func plannedChanges(plan *tfjson.Plan) []PlannedChange {
+	changes := []PlannedChange{}
+	for _, resourceChange := range plan.ResourceChanges {
+		if resourceChange.Change == nil || resourceChange.Mode == tfjson.DataResourceMode {
+			continue
+		}
+		action := planAction(resourceChange.Change.Actions)
+		if action == "" {
+			continue
+		}
+		changes = append(changes, PlannedChange{
+			Address:    resourceChange.Address,
+			Type:       resourceChange.Type,
+			Action:     action,
+			Attributes: changedAttributes(resourceChange.Change),
+		})
+	}
+	return changes
+}
+
+// planAction names the action, "" for no-op and read
+func planAction(actions tfjson.Actions) string {
+	switch {
+	case actions.Replace():
+		return "replace"
+	case actions.Delete():
+		return "destroy"
+	case actions.Update():
+		return "update"
+	case actions.Create():
+		return "create"
+	}
+	return ""
+}
+
+// changedAttributes compares before and after, attributes only known after apply count as changed
+func changedAttributes(change *tfjson.Change) []string {
+	before, _ := change.Before.(map[string]interface{})
+	after, _ := change.After.(map[string]interface{})
+	afterUnknown, _ := change.AfterUnknown.(map[string]interface{})
+
+	changed := map[string]bool{}
+	for name, value := range before {
+		if !reflect.DeepEqual(value, after[name]) {
+			changed[name] = true
+		}
+	}
+	for name, value := range after {
+		if _, ok := before[name]; !ok && value != nil {
+			changed[name] = true
+		}
+	}
+	for name, unknown := range afterUnknown {
+		if unknown == true {
+			changed[name] = true
+		}
+	}
+
+	attributes := []string{}
+	for name := range changed {
+		attributes = append(attributes, name)
+	}
+	sort.Strings(attributes)
+	return attributes
+}
+
+// PrintPlannedChanges writes a table of the planned changes, replacements and destroys are flagged with !!
+// and critical ones with CRITICAL
+func PrintPlannedChanges(out io.Writer, changes []PlannedChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "Plan is clean, the imported resources match the configuration")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tACTION\tADDRESS\tATTRIBUTES\t")
+	counts := map[string]int{}
+	for _, change := range changes {
+		flag := ""
+		if change.Critical() {
+			flag = "CRITICAL"
+		} else if change.Action == "replace" || change.Action == "destroy" {
+			flag = "!!"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", flag, change.Action, change.Address, valueOrDash(strings.Join(change.Attributes, ", ")))
+		counts[change.Action]++
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n%d to create, %d to update, %d to replace, %d to destroy\n", counts["create"], counts["update"], counts["replace"], counts["destroy"])
+}